@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/dump"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/filter"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/flags"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/schema"
+)
+
+// describe prints the described columns of the tables and the fields they
+// are mapped to, the schema is written into the snapshot file if it is set
+func describe(f flags.Flags) int {
+	driver, db := connect(f)
+	defer db.Close()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	snapshot, err := describeSchema(ctx, f, driver, db)
+	if err == nil && f.Snapshot != "" {
+		err = schema.Write(f.Snapshot, snapshot)
+	}
+	if err == nil {
+		err = printDescribe(os.Stdout, driver, snapshot.Tables, snapshot.InsertionOrder)
+	}
+	return exitCode(err, "Describe interrupted")
+}
+
+// plan prints the insertion order, the number of rows and the generator of
+// each column
+func plan(f flags.Flags) int {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	driver, snapshot, err := loadSchema(ctx, f)
+	if err != nil {
+		return exitCode(err, "Plan interrupted")
+	}
+	tableToRowCount, err := rowCounts(f, snapshot.InsertionOrder)
+	if err == nil {
+		err = printPlan(os.Stdout, driver, snapshot.Tables, snapshot.InsertionOrder, tableToRowCount)
+	}
+	return exitCode(err, "Plan interrupted")
+}
+
+// dumpRows generates the rows into the output instead of inserting them
+func dumpRows(f flags.Flags) int {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	driver, snapshot, err := loadSchema(ctx, f)
+	if err != nil {
+		return exitCode(err, "Dump interrupted")
+	}
+	tableToRowCount, err := rowCounts(f, snapshot.InsertionOrder)
+	if err != nil {
+		return exitCode(err, "Dump interrupted")
+	}
+	var w io.Writer = os.Stdout
+	if f.Output != "-" {
+		file, err := os.Create(f.Output)
+		if err != nil {
+			return exitCode(err, "Dump interrupted")
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				log.Print(err)
+			}
+		}()
+		w = file
+	}
+	err = dump.Dump(w, f.DumpFormat, generator.NewRand(int64(f.Seed)), driver, snapshot.Tables,
+		snapshot.InsertionOrder, tableToRowCount)
+	return exitCode(err, "Dump interrupted")
+}
+
+// describeSchema selects and describes the tables of the flags
+func describeSchema(ctx context.Context, f flags.Flags, driver types.Driver, db *sql.DB) (schema.Snapshot, error) {
+	tables, err := selectTables(ctx, f, driver, db)
+	if err != nil {
+		return schema.Snapshot{}, err
+	}
+	tableToFields, insertionOrder, err := describeTables(ctx, driver, db, tables)
+	if err != nil {
+		return schema.Snapshot{}, err
+	}
+	return schema.Snapshot{Driver: driver.Driver(), InsertionOrder: insertionOrder, Tables: tableToFields}, nil
+}
+
+// loadSchema reads the schema from the snapshot file of the flags without
+// connecting to the database, or describes the tables of the database if
+// the snapshot is not set. It returns the driver of the schema.
+func loadSchema(ctx context.Context, f flags.Flags) (types.Driver, schema.Snapshot, error) {
+	if f.Snapshot == "" {
+		driver, db := connect(f)
+		defer db.Close()
+		snapshot, err := describeSchema(ctx, f, driver, db)
+		return driver, snapshot, err
+	}
+	snapshot, err := schema.Read(f.Snapshot)
+	if err != nil {
+		return nil, snapshot, err
+	}
+	driver, err := drivers.New(types.Flags{Driver: snapshot.Driver, Types: f.Driver.Types})
+	if err != nil {
+		return nil, snapshot, err
+	}
+	snapshot.InsertionOrder, err = selectSnapshotTables(f, snapshot)
+	return driver, snapshot, err
+}
+
+// selectSnapshotTables returns the chosen table or the tables of the
+// snapshot matching the include and exclude patterns in insertion order
+func selectSnapshotTables(f flags.Flags, snapshot schema.Snapshot) ([]string, error) {
+	if f.Table != "" {
+		if _, ok := snapshot.Tables[f.Table]; !ok {
+			return nil, fmt.Errorf("table %s is not in the snapshot %s", f.Table, f.Snapshot)
+		}
+		return []string{f.Table}, nil
+	}
+	tableFilter, err := filter.New(f.Include, f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return tableFilter.Apply(snapshot.InsertionOrder), nil
+}
+
+// rowCounts resolves the number of rows of the tables
+func rowCounts(f flags.Flags, tables []string) (map[string]int, error) {
+	tableToRowCount := make(map[string]int, len(tables))
+	for _, table := range tables {
+		count, err := f.Rows.Resolve(table, f.Num)
+		if err != nil {
+			return nil, err
+		}
+		tableToRowCount[table] = count
+	}
+	return tableToRowCount, nil
+}
+
+// printDescribe writes the columns of the tables with the mapped fields
+func printDescribe(w io.Writer, driver types.Driver, tableToFields map[string][]types.FieldDescriptor,
+	insertionOrder []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, table := range insertionOrder {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\n", table)
+		fmt.Fprintln(tw, "  COLUMN\tTYPE\tNULL\tKEY\tDEFAULT\tEXTRA\tREFERENCES\tFIELD\tLENGTH")
+		for _, descriptor := range tableToFields[table] {
+			field := driver.MapField(descriptor)
+			references := ""
+			if fk := descriptor.ForeignKeyDescriptor; fk != nil {
+				references = fk.ForeignTableName + "." + fk.ForeignColumnName
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n", descriptor.Field, descriptor.Type, descriptor.Null,
+				descriptor.Key, descriptor.Default.String, descriptor.Extra, references, field.Type, field.Length)
+		}
+	}
+	return tw.Flush()
+}
+
+// printPlan writes the insertion order and the generator of the columns
+func printPlan(w io.Writer, driver types.Driver, tableToFields map[string][]types.FieldDescriptor,
+	insertionOrder []string, tableToRowCount map[string]int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Insertion order: %s\n", strings.Join(insertionOrder, ", "))
+	for _, table := range insertionOrder {
+		fmt.Fprintf(tw, "\n%s (%d rows)\n", table, tableToRowCount[table])
+		fmt.Fprintln(tw, "  COLUMN\tGENERATOR")
+		for _, descriptor := range tableToFields[table] {
+			fmt.Fprintf(tw, "  %s\t%s\n", descriptor.Field, describeGenerator(driver, descriptor))
+		}
+	}
+	return tw.Flush()
+}
+
+// describeGenerator describes how the value of the column is generated
+func describeGenerator(driver types.Driver, descriptor types.FieldDescriptor) string {
+	if descriptor.HasDefaultValue {
+		return "database default"
+	}
+	if fk := descriptor.ForeignKeyDescriptor; fk != nil {
+		return fmt.Sprintf("foreign key %s.%s", fk.ForeignTableName, fk.ForeignColumnName)
+	}
+	field := driver.MapField(descriptor)
+	switch {
+	case field.Type == types.Enum:
+		return fmt.Sprintf("enum (%s)", strings.Join(field.Enum, ", "))
+	case field.Length > 0:
+		return fmt.Sprintf("%s(%d)", field.Type, field.Length)
+	}
+	return field.Type.String()
+}
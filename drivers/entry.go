@@ -1,34 +1,83 @@
 package drivers
 
 import (
-	"log"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers/mysql"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/postgres"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
 )
 
-// New creates a new driver instance based on the flags
-func New(f types.Flags) types.Driver {
-	switch f.Driver {
-	case "mysql":
-		return mysql.New(f)
-	case "postgres":
-		return postgres.New(f)
-	default:
-		log.Fatal("Driver not implemented")
-		return nil
+// Factory creates a driver instance based on the flags
+type Factory func(f types.Flags) types.Driver
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register("mysql", func(f types.Flags) types.Driver { return mysql.New(f) })
+	Register("postgres", func(f types.Flags) types.Driver { return postgres.New(f) })
+}
+
+// Register makes a driver available by the name. If Register is called
+// twice with the same name or the factory is nil, it panics.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("drivers: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("drivers: Register called twice for driver " + name)
 	}
+	factories[name] = factory
+}
+
+// unregister removes the driver registered by the name
+func unregister(name string) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	delete(factories, name)
 }
 
-func NewTestable(f types.Flags) types.Testable {
-	switch f.Driver {
-	case "mysql":
-		return mysql.New(f)
-	case "postgres":
-		return postgres.New(f)
-	default:
-		log.Fatal("Testable not implemented")
-		return nil
+// Drivers returns the sorted names of the registered drivers
+func Drivers() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a new driver instance based on the flags, the types of the
+// flags override the mapping of the driver
+func New(f types.Flags) (types.Driver, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[f.Driver]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("drivers: unknown driver %q (available: %s)", f.Driver, strings.Join(Drivers(), ", "))
+	}
+	return WithTypes(factory(f), f.Types), nil
+}
+
+// NewTestable creates a new driver instance implementing types.Testable
+func NewTestable(f types.Flags) (types.Testable, error) {
+	d, err := New(f)
+	if err != nil {
+		return nil, err
+	}
+	testable, ok := d.(types.Testable)
+	if !ok {
+		return nil, fmt.Errorf("drivers: driver %q is not testable", f.Driver)
 	}
+	return testable, nil
 }
@@ -0,0 +1,47 @@
+package drivers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// driver is embedded by fakeDriver, the alias avoids the clash of the
+// embedded field with the Driver method
+type driver = types.Driver
+
+// fakeDriver implements types.Driver by the embedded nil interface
+type fakeDriver struct {
+	driver
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake", func(types.Flags) types.Driver { return fakeDriver{} })
+	defer unregister("fake")
+
+	if names := Drivers(); !reflect.DeepEqual(names, []string{"fake", "mysql", "postgres"}) {
+		t.Errorf("Invalid drivers: %v", names)
+	}
+	if d, err := New(types.Flags{Driver: "fake"}); err != nil || d != types.Driver(fakeDriver{}) {
+		t.Errorf("Invalid registered driver: %v, %v", d, err)
+	}
+	if _, err := NewTestable(types.Flags{Driver: "fake"}); err == nil {
+		t.Error("Fake driver should not be testable")
+	}
+	if _, err := NewTestable(types.Flags{Driver: "postgres"}); err != nil {
+		t.Error(err)
+	}
+	_, err := New(types.Flags{Driver: "oracle"})
+	if err == nil || !strings.Contains(err.Error(), "fake, mysql, postgres") {
+		t.Errorf("Unknown driver should list the available drivers: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Registering a driver twice should panic")
+		}
+	}()
+	Register("mysql", func(types.Flags) types.Driver { return fakeDriver{} })
+}
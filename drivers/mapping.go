@@ -0,0 +1,123 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// typeMapper maps the column types of the mapping and leaves the others to
+// the wrapped driver
+type typeMapper struct {
+	base    types.Driver
+	mapping map[string]types.Field
+}
+
+// testableTypeMapper is the typeMapper of the testable drivers
+type testableTypeMapper struct {
+	typeMapper
+	types.Testable
+}
+
+// WithTypes wraps the driver to map the raw column types of the mapping,
+// the other types are mapped by the driver. The types are matched case
+// insensitively, with and without their arguments: numeric(10,2) is
+// mapped by numeric(10,2) or numeric. The wrapper implements
+// types.Testable if the driver does.
+func WithTypes(d types.Driver, mapping map[string]types.Field) types.Driver {
+	if len(mapping) == 0 {
+		return d
+	}
+	normalized := make(map[string]types.Field, len(mapping))
+	for rawType, field := range mapping {
+		normalized[normalizeType(rawType)] = field
+	}
+	mapper := typeMapper{base: d, mapping: normalized}
+	if testable, ok := d.(types.Testable); ok {
+		return testableTypeMapper{typeMapper: mapper, Testable: testable}
+	}
+	return mapper
+}
+
+// MapField maps the column by the mapping, the length of the column is
+// used if the mapped field has no length
+func (m typeMapper) MapField(descriptor types.FieldDescriptor) types.Field {
+	rawType := normalizeType(descriptor.Type)
+	field, ok := m.mapping[rawType]
+	if !ok {
+		if i := strings.Index(rawType, "("); i >= 0 {
+			field, ok = m.mapping[strings.TrimSpace(rawType[:i])]
+		}
+	}
+	if !ok {
+		return m.base.MapField(descriptor)
+	}
+	if field.Length <= 0 {
+		field.Length = -1
+		if descriptor.Length.Valid && descriptor.Length.Int > 0 {
+			field.Length = int16(descriptor.Length.Int)
+		}
+	}
+	return field
+}
+
+// normalizeType lowercases the type and removes the spaces around the
+// arguments
+func normalizeType(rawType string) string {
+	rawType = strings.ToLower(strings.TrimSpace(rawType))
+	return strings.Join(strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ", ",", " , ").Replace(rawType)), " ")
+}
+
+func (m typeMapper) ShowTables(ctx context.Context, db *sql.DB) ([]types.Table, error) {
+	return m.base.ShowTables(ctx, db)
+}
+
+func (m typeMapper) Connection() string {
+	return m.base.Connection()
+}
+
+func (m typeMapper) Driver() string {
+	return m.base.Driver()
+}
+
+func (m typeMapper) Insert(fields []string, table string) string {
+	return m.base.Insert(fields, table)
+}
+
+func (m typeMapper) Returning(columns []string) string {
+	return m.base.Returning(columns)
+}
+
+func (m typeMapper) Delete(table string, columns []string) string {
+	return m.base.Delete(table, columns)
+}
+
+func (m typeMapper) Truncate(ctx context.Context, tables []string, db *sql.DB) error {
+	return m.base.Truncate(ctx, tables, db)
+}
+
+func (m typeMapper) Describe(ctx context.Context, table string, db *sql.DB) ([]types.FieldDescriptor, error) {
+	return m.base.Describe(ctx, table, db)
+}
+
+func (m typeMapper) MultiDescribe(ctx context.Context, tables []string, db *sql.DB) (map[string][]types.FieldDescriptor, []string, error) {
+	return m.base.MultiDescribe(ctx, tables, db)
+}
+
+func (m typeMapper) GetLatestColumnValue(ctx context.Context, table, column string, db *sql.DB) (interface{}, error) {
+	return m.base.GetLatestColumnValue(ctx, table, column, db)
+}
+
+func (m typeMapper) CountRows(ctx context.Context, table string, db *sql.DB) (int, error) {
+	return m.base.CountRows(ctx, table, db)
+}
+
+func (m typeMapper) TableSize(ctx context.Context, table string, db *sql.DB) (int64, error) {
+	return m.base.TableSize(ctx, table, db)
+}
+
+func (m typeMapper) ClassifyError(err error) types.ErrorCategory {
+	return m.base.ClassifyError(err)
+}
@@ -0,0 +1,61 @@
+package drivers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/volatiletech/null"
+)
+
+func TestWithTypes(t *testing.T) {
+	driver, err := New(types.Flags{Driver: "postgres", Types: map[string]types.Field{
+		"CITEXT":         {Type: types.Text},
+		"geometry":       {Type: types.String, Length: 64},
+		"numeric(10, 2)": {Type: types.Int32},
+		"mood":           {Type: types.Enum, Enum: []string{"happy", "sad"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scenarios = []struct {
+		input  types.FieldDescriptor
+		output types.Field
+	}{
+		{input: types.FieldDescriptor{Type: "citext"}, output: types.Field{Type: types.Text, Length: -1}},
+		{input: types.FieldDescriptor{Type: "citext", Length: null.IntFrom(20)}, output: types.Field{Type: types.Text, Length: 20}},
+		{input: types.FieldDescriptor{Type: "geometry(Point,4326)"}, output: types.Field{Type: types.String, Length: 64}},
+		{input: types.FieldDescriptor{Type: "numeric(10,2)"}, output: types.Field{Type: types.Int32, Length: -1}},
+		{input: types.FieldDescriptor{Type: "mood"}, output: types.Field{Type: types.Enum, Length: -1, Enum: []string{"happy", "sad"}}},
+		{input: types.FieldDescriptor{Type: "integer"}, output: types.Field{Type: types.Int32, Length: -1}},
+	}
+
+	for _, scenario := range scenarios {
+		if output := driver.MapField(scenario.input); !reflect.DeepEqual(output, scenario.output) {
+			t.Errorf("Invalid field of %s, out: %+v expected: %+v", scenario.input.Type, output, scenario.output)
+		}
+	}
+	if driver.Driver() != "postgres" {
+		t.Errorf("Invalid driver name of the wrapped driver: %s", driver.Driver())
+	}
+}
+
+func TestWithTypesTestable(t *testing.T) {
+	mapping := map[string]types.Field{"citext": {Type: types.Text}}
+	testable, err := NewTestable(types.Flags{Driver: "postgres", Types: mapping})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field := testable.(types.Driver).MapField(types.FieldDescriptor{Type: "citext"}); field.Type != types.Text {
+		t.Errorf("Invalid field of the testable driver: %+v", field)
+	}
+	if _, err := testable.GetTestCase("single"); err != nil {
+		t.Error(err)
+	}
+
+	fake := WithTypes(fakeDriver{}, mapping)
+	if _, ok := fake.(types.Testable); ok {
+		t.Error("Wrapper of a driver which is not testable should not be testable")
+	}
+}
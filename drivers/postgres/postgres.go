@@ -1,13 +1,19 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/utils"
+	"github.com/lib/pq"
 )
 
 /*
@@ -67,12 +73,34 @@ const CreateTable = `CREATE TABLE IF NOT EXISTS %s (
 `
 
 const (
-	PSQLDescribeTemplate = `select column_name, data_type, character_maximum_length, column_default, is_nullable,numeric_precision,numeric_scale
+	// PSQLDescribeTemplate returns the name of the extension types, enums
+	// and other user-defined types instead of USER-DEFINED
+	PSQLDescribeTemplate = `select column_name, case when data_type = 'USER-DEFINED' then udt_name else data_type end,
+                            character_maximum_length, column_default, is_nullable,numeric_precision,numeric_scale
                             from INFORMATION_SCHEMA.COLUMNS where table_name = '%s'`
-	PSQLConnectionTemplate = "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable"
+	PSQLConnectionTemplate = "host=%s port=%s user=%s password=%s dbname=%s"
 	PSQLInsertTemplate     = `INSERT INTO %s("%s") VALUES(%s)`
-	PSQLShowTablesQuery    = "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname != 'pg_catalog' AND schemaname != 'information_schema';"
-	psqlForeignKeysQuery   = `
+	PSQLReturningTemplate  = ` RETURNING "%s"`
+	PSQLDeleteTemplate     = `DELETE FROM %s WHERE %s`
+	pgSocketPrefix         = ".s.PGSQL."
+	PSQLShowTablesQuery    = `
+SELECT
+    c.relname,
+    c.relkind,
+    c.relispartition,
+    coalesce(parent.relname, '')
+FROM
+    pg_catalog.pg_class AS c
+    JOIN pg_catalog.pg_namespace AS n ON n.oid = c.relnamespace
+    LEFT JOIN pg_catalog.pg_inherits AS i ON i.inhrelid = c.oid AND c.relispartition
+    LEFT JOIN pg_catalog.pg_class AS parent ON parent.oid = i.inhparent
+WHERE c.relkind IN ('r', 'v', 'm', 'p', 'f')
+    AND n.nspname != 'pg_catalog' AND n.nspname != 'information_schema' AND n.nspname NOT LIKE 'pg_toast%';`
+	psqlTableSizeQuery = `
+SELECT coalesce(sum(pg_total_relation_size(c.oid)), 0)
+FROM pg_catalog.pg_class AS c
+WHERE c.oid = $1::regclass OR c.oid IN (SELECT inhrelid FROM pg_catalog.pg_inherits WHERE inhparent = $1::regclass)`
+	psqlForeignKeysQuery = `
 	SELECT
     tc.constraint_name, 
     tc.table_name, 
@@ -89,6 +117,14 @@ FROM
       AND ccu.table_schema = tc.table_schema
 WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name='%s'
 	`
+	psqlPrimaryKeyQuery = `
+SELECT kcu.column_name
+FROM
+    information_schema.table_constraints AS tc
+    JOIN information_schema.key_column_usage AS kcu
+      ON tc.constraint_name = kcu.constraint_name
+      AND tc.table_schema = kcu.table_schema
+WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_name='%s'`
 )
 
 var (
@@ -151,25 +187,78 @@ func New(f types.Flags) Postgres {
 	}
 }
 
-func (p Postgres) ShowTables(db *sql.DB) ([]string, error) {
-	rows, err := db.Query(PSQLShowTablesQuery)
+// ShowTables returns the relations of the database with their kind
+func (p Postgres) ShowTables(ctx context.Context, db *sql.DB) ([]types.Table, error) {
+	rows, err := db.QueryContext(ctx, PSQLShowTablesQuery)
 	if err != nil {
 		return nil, err
 	}
-	var tables []string
+	defer rows.Close()
+	var tables []types.Table
 	for rows.Next() {
-		var table string
-		if err := rows.Scan(&table); err != nil {
+		var table types.Table
+		var relKind string
+		var isPartition bool
+		if err := rows.Scan(&table.Name, &relKind, &isPartition, &table.Parent); err != nil {
 			return nil, err
 		}
+		table.Kind = pgTableKind(relKind, isPartition)
 		tables = append(tables, table)
 	}
 	return tables, nil
 }
 
+// Connection returns the connection string, the DSN is used if it is set.
+// The TLS flags are added as sslmode, sslrootcert, sslcert and sslkey.
 func (p Postgres) Connection() string {
-	return fmt.Sprintf(PSQLConnectionTemplate,
-		p.f.Host, p.f.Port, p.f.Username, p.f.Password, p.f.Database)
+	sslParams := p.sslParams()
+	if p.f.DSN == "" {
+		if _, ok := sslParams["sslmode"]; !ok {
+			sslParams["sslmode"] = utils.TLSDisable
+		}
+		host, port := p.f.Host, p.f.Port
+		if p.f.Socket != "" {
+			host, port = pgSocket(p.f.Socket, port)
+		}
+		return fmt.Sprintf(PSQLConnectionTemplate,
+			host, port, p.f.Username, p.f.Password, p.f.Database) + pgKeyValues(sslParams)
+	}
+	// lib/pq accepts both postgres:// URLs and key=value strings
+	if len(sslParams) == 0 {
+		return p.f.DSN
+	}
+	if strings.Contains(p.f.DSN, "://") {
+		u, err := url.Parse(p.f.DSN)
+		if err != nil {
+			log.Print(err)
+			return p.f.DSN
+		}
+		query := u.Query()
+		for key, value := range sslParams {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return p.f.DSN + pgKeyValues(sslParams)
+}
+
+// sslParams returns the connection parameters of the TLS flags
+func (p Postgres) sslParams() map[string]string {
+	params := make(map[string]string)
+	if p.f.TLSMode != "" {
+		params["sslmode"] = p.f.TLSMode
+	}
+	if p.f.TLSCA != "" {
+		params["sslrootcert"] = p.f.TLSCA
+	}
+	if p.f.TLSCert != "" {
+		params["sslcert"] = p.f.TLSCert
+	}
+	if p.f.TLSKey != "" {
+		params["sslkey"] = p.f.TLSKey
+	}
+	return params
 }
 
 func (p Postgres) Driver() string {
@@ -180,6 +269,30 @@ func (p Postgres) Insert(fields []string, table string) string {
 	return fmt.Sprintf(PSQLInsertTemplate, table, strings.Join(fields, `","`), pgValPlaceholder(len(fields)))
 }
 
+// Returning returns the RETURNING clause of the columns
+func (p Postgres) Returning(columns []string) string {
+	return fmt.Sprintf(PSQLReturningTemplate, strings.Join(columns, `","`))
+}
+
+// Delete returns the query deleting the rows matching the columns
+func (p Postgres) Delete(table string, columns []string) string {
+	conditions := make([]string, 0, len(columns))
+	for i, column := range columns {
+		conditions = append(conditions, fmt.Sprintf(`"%s" = $%d`, column, i+1))
+	}
+	return fmt.Sprintf(PSQLDeleteTemplate, table, strings.Join(conditions, " AND "))
+}
+
+// Truncate empties the tables with TRUNCATE ... CASCADE, the tables
+// referencing them are emptied as well
+func (p Postgres) Truncate(ctx context.Context, tables []string, db *sql.DB) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", strings.Join(tables, ", ")))
+	return err
+}
+
 //nolint:cyclop
 func (p Postgres) MapField(descriptor types.FieldDescriptor) types.Field {
 	field := types.Field{Type: types.Unknown, Length: -1}
@@ -219,11 +332,11 @@ func (p Postgres) MapField(descriptor types.FieldDescriptor) types.Field {
 	return field
 }
 
-func (p Postgres) MultiDescribe(tables []string, db *sql.DB) (tableToDescriptorMap map[string][]types.FieldDescriptor, insertionOrder []string, err error) {
+func (p Postgres) MultiDescribe(ctx context.Context, tables []string, db *sql.DB) (tableToDescriptorMap map[string][]types.FieldDescriptor, insertionOrder []string, err error) {
 	processedTables := make(map[string]struct{})
 	tableToDescriptorMap = make(map[string][]types.FieldDescriptor)
 	for {
-		newTableToDescriptorMap, newlyReferencedTables, err := utils.MultiDescribeHelper(tables, processedTables, db, p)
+		newTableToDescriptorMap, newlyReferencedTables, err := utils.MultiDescribeHelper(ctx, tables, processedTables, db, p)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -242,21 +355,25 @@ func (p Postgres) MultiDescribe(tables []string, db *sql.DB) (tableToDescriptorM
 	return tableToDescriptorMap, insertionOrder, nil
 }
 
-func (p Postgres) Describe(table string, db *sql.DB) ([]types.FieldDescriptor, error) {
-	results, err := db.Query(fmt.Sprintf(PSQLDescribeTemplate, strings.ToLower(table)))
+func (p Postgres) Describe(ctx context.Context, table string, db *sql.DB) ([]types.FieldDescriptor, error) {
+	results, err := db.QueryContext(ctx, fmt.Sprintf(PSQLDescribeTemplate, strings.ToLower(table)))
 	if err != nil {
 		return nil, err
 	}
-	fkResults, err := db.Query(fmt.Sprintf(psqlForeignKeysQuery, strings.ToLower(table)))
+	fkResults, err := db.QueryContext(ctx, fmt.Sprintf(psqlForeignKeysQuery, strings.ToLower(table)))
 	if err != nil {
 		return nil, err
 	}
-	return parsePostgresFields(results, fkResults)
+	pkResults, err := db.QueryContext(ctx, fmt.Sprintf(psqlPrimaryKeyQuery, strings.ToLower(table)))
+	if err != nil {
+		return nil, err
+	}
+	return parsePostgresFields(results, fkResults, pkResults)
 }
 
-func (p Postgres) GetLatestColumnValue(table, column string, db *sql.DB) (interface{}, error) {
+func (p Postgres) GetLatestColumnValue(ctx context.Context, table, column string, db *sql.DB) (interface{}, error) {
 	query := fmt.Sprintf("select %s from %s order by %s desc limit 1", column, table, column)
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -269,6 +386,47 @@ func (p Postgres) GetLatestColumnValue(table, column string, db *sql.DB) (interf
 	return val, nil
 }
 
+// CountRows returns the number of rows in the table
+func (p Postgres) CountRows(ctx context.Context, table string, db *sql.DB) (int, error) {
+	return utils.CountRows(ctx, table, db)
+}
+
+// TableSize returns the size of the table on disk including indexes, TOAST
+// and the direct partitions of partitioned tables
+func (p Postgres) TableSize(ctx context.Context, table string, db *sql.DB) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx, psqlTableSizeQuery, table).Scan(&size)
+	return size, err
+}
+
+// ClassifyError returns the category of an insert error based on the
+// SQLSTATE class
+func (p Postgres) ClassifyError(err error) types.ErrorCategory {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return utils.ClassifyCommonError(err)
+	}
+	switch pqErr.Code {
+	case "57014": // query_canceled, statement_timeout
+		return types.TimeoutError
+	case "53300", "57P01", "57P02", "57P03": // too_many_connections, shutdowns
+		return types.ConnectionError
+	case "55P03": // lock_not_available
+		return types.TransientError
+	}
+	switch pqErr.Code.Class() {
+	case "23": // integrity_constraint_violation
+		return types.ConstraintViolation
+	case "22": // data_exception
+		return types.TypeMismatch
+	case "08": // connection_exception
+		return types.ConnectionError
+	case "40": // transaction_rollback, serialization_failure, deadlock_detected
+		return types.TransientError
+	}
+	return types.OtherError
+}
+
 // TestTable only for test purposes
 func (p Postgres) TestTable(db *sql.DB, testCase, table string) error {
 	return utils.TestTable(db, testCase, table, p)
@@ -281,8 +439,16 @@ func (Postgres) GetTestCase(name string) (types.TestCase, error) {
 	return types.TestCase{}, fmt.Errorf("postgres: Error getting testcase with name %v", name)
 }
 
-func parsePostgresFields(rows, fkRows *sql.Rows) ([]types.FieldDescriptor, error) {
+func parsePostgresFields(rows, fkRows, pkRows *sql.Rows) ([]types.FieldDescriptor, error) {
 	var tableFields []types.FieldDescriptor
+	primaryKey := make(map[string]struct{})
+	for pkRows.Next() {
+		var column string
+		if err := pkRows.Scan(&column); err != nil {
+			return nil, err
+		}
+		primaryKey[column] = struct{}{}
+	}
 	columnToFKMap := make(map[string]types.FKDescriptor)
 	for fkRows.Next() {
 		var fk types.FKDescriptor
@@ -302,11 +468,64 @@ func parsePostgresFields(rows, fkRows *sql.Rows) ([]types.FieldDescriptor, error
 		if val, ok := columnToFKMap[field.Field]; ok {
 			field.ForeignKeyDescriptor = &val
 		}
+		if _, ok := primaryKey[field.Field]; ok {
+			field.Key = "PRI"
+		}
 		tableFields = append(tableFields, field)
 	}
 	return tableFields, nil
 }
 
+// pgTableKind maps the relkind of pg_class
+func pgTableKind(relKind string, isPartition bool) types.TableKind {
+	if isPartition {
+		return types.Partition
+	}
+	switch relKind {
+	case "r":
+		return types.BaseTable
+	case "v":
+		return types.View
+	case "m":
+		return types.MaterializedView
+	case "p":
+		return types.PartitionedTable
+	case "f":
+		return types.ForeignTable
+	}
+	return types.View
+}
+
+// pgSocket returns the host and port of the Unix domain socket. The host is
+// the directory of the socket, the socket file (.s.PGSQL.5432) can be given
+// as well and its suffix is the port.
+func pgSocket(socket, port string) (string, string) {
+	base := filepath.Base(socket)
+	if strings.HasPrefix(base, pgSocketPrefix) {
+		return filepath.Dir(socket), strings.TrimPrefix(base, pgSocketPrefix)
+	}
+	return socket, port
+}
+
+// pgKeyValues formats the parameters as key=value pairs of the connection
+// string, the values are quoted if needed
+func pgKeyValues(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		value := params[key]
+		if value == "" || strings.ContainsAny(value, ` '\`) {
+			value = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+		}
+		fmt.Fprintf(&b, " %s=%s", key, value)
+	}
+	return b.String()
+}
+
 func pgValPlaceholder(fieldLen int) string {
 	var q []string
 	for i := 1; i <= fieldLen; i++ {
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -32,12 +33,12 @@ func ExamplePostgres_ShowTables() {
 		return
 	}
 	driver := Postgres{}
-	tables, err := driver.ShowTables(db)
+	tables, err := driver.ShowTables(context.Background(), db)
 	if err != nil {
 		log.Printf("Error showing tables : %s", err.Error())
 		return
 	}
 	for _, table := range tables {
-		fmt.Println(table)
+		fmt.Println(table.Name, table.Kind)
 	}
 }
@@ -1,12 +1,14 @@
 package postgres
 
 import (
+	"context"
+	"database/sql/driver"
 	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/volatiletech/null"
 )
 
@@ -43,6 +45,93 @@ func TestPostgres_MapField(t *testing.T) {
 	}
 }
 
+func TestPostgres_ClassifyError(t *testing.T) {
+	var scenarios = []struct {
+		input  error
+		output types.ErrorCategory
+	}{
+		{&pq.Error{Code: "23505"}, types.ConstraintViolation},
+		{&pq.Error{Code: "23503"}, types.ConstraintViolation},
+		{&pq.Error{Code: "22001"}, types.TypeMismatch},
+		{&pq.Error{Code: "08006"}, types.ConnectionError},
+		{&pq.Error{Code: "57014"}, types.TimeoutError},
+		{&pq.Error{Code: "40001"}, types.TransientError},
+		{&pq.Error{Code: "40P01"}, types.TransientError},
+		{&pq.Error{Code: "42P01"}, types.OtherError},
+		{driver.ErrBadConn, types.ConnectionError},
+	}
+
+	for _, scenario := range scenarios {
+		if output := (Postgres{}).ClassifyError(scenario.input); output != scenario.output {
+			t.Errorf("Invalid category for %v, out: %s expected: %s", scenario.input, output, scenario.output)
+		}
+	}
+}
+
+func TestPostgres_Delete(t *testing.T) {
+	var scenarios = []struct {
+		table   string
+		columns []string
+		output  string
+	}{
+		{"users", []string{"id"}, `DELETE FROM users WHERE "id" = $1`},
+		{"order_items", []string{"order_id", "sku"}, `DELETE FROM order_items WHERE "order_id" = $1 AND "sku" = $2`},
+	}
+
+	for _, scenario := range scenarios {
+		if output := (Postgres{}).Delete(scenario.table, scenario.columns); output != scenario.output {
+			t.Errorf("Invalid delete query, out: %s expected: %s", output, scenario.output)
+		}
+	}
+	if output := (Postgres{}).Returning([]string{"id", "code"}); output != ` RETURNING "id","code"` {
+		t.Errorf("Invalid returning clause: %s", output)
+	}
+}
+
+func TestPostgres_Connection(t *testing.T) {
+	var scenarios = []struct {
+		flags  types.Flags
+		output string
+	}{
+		{
+			flags:  types.Flags{Host: "localhost", Port: "5432", Username: "test", Password: "test", Database: "test"},
+			output: "host=localhost port=5432 user=test password=test dbname=test sslmode=disable",
+		},
+		{
+			flags: types.Flags{Host: "db", Port: "5432", Username: "test", Password: "test", Database: "test",
+				TLSMode: "verify-full", TLSCA: "/etc/ssl/my ca.pem", TLSCert: "client.pem", TLSKey: "client-key.pem"},
+			output: "host=db port=5432 user=test password=test dbname=test sslcert=client.pem sslkey=client-key.pem " +
+				"sslmode=verify-full sslrootcert='/etc/ssl/my ca.pem'",
+		},
+		{
+			flags:  types.Flags{Socket: "/var/run/postgresql", Port: "5432", Username: "test", Password: "test", Database: "test"},
+			output: "host=/var/run/postgresql port=5432 user=test password=test dbname=test sslmode=disable",
+		},
+		{
+			flags:  types.Flags{Socket: "/tmp/.s.PGSQL.5433", Port: "5432", Username: "test", Password: "test", Database: "test"},
+			output: "host=/tmp port=5433 user=test password=test dbname=test sslmode=disable",
+		},
+		{
+			flags:  types.Flags{DSN: "postgres://test:test@db/test?connect_timeout=5"},
+			output: "postgres://test:test@db/test?connect_timeout=5",
+		},
+		{
+			flags:  types.Flags{DSN: "postgres://test:test@db/test?sslmode=disable", TLSMode: "require"},
+			output: "postgres://test:test@db/test?sslmode=require",
+		},
+		{
+			flags:  types.Flags{DSN: "host=db dbname=test", TLSMode: "require"},
+			output: "host=db dbname=test sslmode=require",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		if output := New(scenario.flags).Connection(); output != scenario.output {
+			t.Errorf("Invalid connection string, out: %s expected: %s", output, scenario.output)
+		}
+	}
+}
+
 func TestPostgres_MultiDescribe(t *testing.T) {
 	db, err := getPostgresConnection()
 	pgDriver := Postgres{}
@@ -59,7 +148,7 @@ func TestPostgres_MultiDescribe(t *testing.T) {
 		t.Errorf("error initializing multi test case : %v", err.Error())
 	}
 	tables := testCase.TableCreationOrder
-	tableFieldsMap, insertionOrder, err := Postgres{}.MultiDescribe(tables, db)
+	tableFieldsMap, insertionOrder, err := Postgres{}.MultiDescribe(context.Background(), tables, db)
 	if err != nil {
 		t.Errorf("error descriving tables %v. Error : %v", tables, err)
 	}
@@ -1,7 +1,11 @@
 package types
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/volatiletech/null"
 )
@@ -26,6 +30,112 @@ const (
 	Unknown
 )
 
+var fieldTypeNames = map[FieldType]string{
+	String:       "string",
+	Int16:        "int16",
+	Int32:        "int32",
+	Float:        "float",
+	Blob:         "blob",
+	Text:         "text",
+	Enum:         "enum",
+	Bool:         "bool",
+	Json:         "json",
+	Time:         "time",
+	Year:         "year",
+	XML:          "xml",
+	UUID:         "uuid",
+	BinaryString: "binary string",
+	Unknown:      "unknown",
+}
+
+// String returns the name of the field type
+func (t FieldType) String() string {
+	if name, ok := fieldTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseField parses a field written as the name of the type with optional
+// length or enum values: int32, string(64), enum(small, large)
+func ParseField(s string) (Field, error) {
+	s = strings.TrimSpace(s)
+	name, args := s, ""
+	if i := strings.Index(s, "("); i >= 0 && strings.HasSuffix(s, ")") {
+		name, args = strings.TrimSpace(s[:i]), s[i+1:len(s)-1]
+	}
+	for fieldType, typeName := range fieldTypeNames {
+		if typeName != name || fieldType == Unknown {
+			continue
+		}
+		field := Field{Type: fieldType, Length: -1}
+		switch {
+		case args == "":
+		case fieldType == Enum:
+			for _, value := range strings.Split(args, ",") {
+				field.Enum = append(field.Enum, strings.TrimSpace(value))
+			}
+		default:
+			length, err := strconv.ParseInt(strings.TrimSpace(args), 10, 16)
+			if err != nil {
+				return Field{}, fmt.Errorf("types: invalid length of %s: %s", name, args)
+			}
+			field.Length = int16(length)
+		}
+		return field, nil
+	}
+	return Field{}, fmt.Errorf("types: unknown field type: %s", name)
+}
+
+// TableKind is the kind of the relation returned by the table discovery
+type TableKind string
+
+const (
+	BaseTable        TableKind = "base table"
+	View             TableKind = "view"
+	MaterializedView TableKind = "materialized view"
+	PartitionedTable TableKind = "partitioned table"
+	Partition        TableKind = "partition"
+	ForeignTable     TableKind = "foreign table"
+)
+
+// Table is a relation returned by the table discovery
+type Table struct {
+	Name string
+	Kind TableKind
+	// Parent is the partitioned table in case of partitions
+	Parent string
+}
+
+// InsertTarget returns the table where the rows should be inserted for this
+// relation. Partitions are routed through their parent, views, materialized
+// views and foreign tables are not insertable.
+func (t Table) InsertTarget() (string, bool) {
+	switch t.Kind {
+	case BaseTable, PartitionedTable:
+		return t.Name, true
+	case Partition:
+		if t.Parent == "" {
+			return t.Name, true
+		}
+		return t.Parent, true
+	default:
+		return "", false
+	}
+}
+
+// ErrorCategory groups the insert errors by their cause
+type ErrorCategory string
+
+const (
+	ConstraintViolation ErrorCategory = "constraint_violation"
+	TypeMismatch        ErrorCategory = "type_mismatch"
+	ConnectionError     ErrorCategory = "connection"
+	TimeoutError        ErrorCategory = "timeout"
+	TransientError      ErrorCategory = "transient" // deadlocks, lock timeouts, serialization failures
+	OtherError          ErrorCategory = "other"
+)
+
 // Flags needed by the driver
 type Flags struct {
 	Username string
@@ -34,6 +144,21 @@ type Flags struct {
 	Host     string
 	Port     string
 	Driver   string
+	// DSN is the native data source name or connection URL of the driver,
+	// it takes precedence over the discrete fields
+	DSN string
+	// Socket is the path of the Unix domain socket, it is used instead of
+	// the host and port if it is set
+	Socket string
+	// TLSMode is disable, require, verify-ca or verify-full, the CA bundle,
+	// client certificate and key are PEM files
+	TLSMode string
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+	// Types maps raw column types to fields, they take precedence over the
+	// mapping of the driver
+	Types map[string]Field
 }
 
 // Field is the possible field definition
@@ -74,14 +199,25 @@ type TestCase struct {
 
 // Driver is the interface should satisfied by a certain driver
 type Driver interface {
-	ShowTables(db *sql.DB) ([]string, error)
+	ShowTables(ctx context.Context, db *sql.DB) ([]Table, error)
 	Connection() string
 	Driver() string
 	Insert(fields []string, table string) string
+	// Returning returns the clause appended to the insert to return the
+	// columns filled by the database, empty if it is not supported
+	Returning(columns []string) string
+	// Delete returns the query deleting the rows matching the columns
+	Delete(table string, columns []string) string
+	// Truncate empties the tables regardless of the foreign keys
+	// referencing them
+	Truncate(ctx context.Context, tables []string, db *sql.DB) error
 	MapField(descriptor FieldDescriptor) Field
-	Describe(table string, db *sql.DB) ([]FieldDescriptor, error)
-	MultiDescribe(tables []string, db *sql.DB) (map[string][]FieldDescriptor, []string, error)
-	GetLatestColumnValue(table, column string, db *sql.DB) (interface{}, error)
+	Describe(ctx context.Context, table string, db *sql.DB) ([]FieldDescriptor, error)
+	MultiDescribe(ctx context.Context, tables []string, db *sql.DB) (map[string][]FieldDescriptor, []string, error)
+	GetLatestColumnValue(ctx context.Context, table, column string, db *sql.DB) (interface{}, error)
+	CountRows(ctx context.Context, table string, db *sql.DB) (int, error)
+	TableSize(ctx context.Context, table string, db *sql.DB) (int64, error)
+	ClassifyError(err error) ErrorCategory
 }
 
 type Testable interface {
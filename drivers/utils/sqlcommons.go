@@ -3,9 +3,14 @@ package utils
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"sort"
 	"strings"
+	"syscall"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
 )
@@ -14,7 +19,7 @@ const (
 	DefaultTableCreateQueryKey = ""
 )
 
-func MultiDescribeHelper(tables []string, processedTables map[string]struct{}, db *sql.DB,
+func MultiDescribeHelper(ctx context.Context, tables []string, processedTables map[string]struct{}, db *sql.DB,
 	d types.Driver) (tableDescriptorMap map[string][]types.FieldDescriptor, newlyReferencedTables []string, err error) {
 	knownTables := make(map[string]bool)
 	tableDescriptorMap = make(map[string][]types.FieldDescriptor)
@@ -22,7 +27,7 @@ func MultiDescribeHelper(tables []string, processedTables map[string]struct{}, d
 		knownTables[table] = true
 	}
 	for _, table := range tables {
-		fields, err := d.Describe(table, db)
+		fields, err := d.Describe(ctx, table, db)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -46,7 +51,8 @@ func GetInsertionOrder(tablesToFieldsMap map[string][]types.FieldDescriptor) ([]
 	var tablesVisitOrder []string
 	tablesVisited := make(map[string]struct{})
 	for len(tablesVisitOrder) < len(tablesToFieldsMap) {
-		newInsertCount := 0
+		// Tables insertable in this pass, sorted to keep the order deterministic.
+		var candidates []string
 		for table, fields := range tablesToFieldsMap {
 			if _, ok := tablesVisited[table]; ok {
 				continue
@@ -56,6 +62,10 @@ func GetInsertionOrder(tablesToFieldsMap map[string][]types.FieldDescriptor) ([]
 				if field.ForeignKeyDescriptor == nil {
 					continue
 				}
+				// Self references don't constrain the order of the tables.
+				if field.ForeignKeyDescriptor.ForeignTableName == table {
+					continue
+				}
 				if _, ok := tablesVisited[field.ForeignKeyDescriptor.ForeignTableName]; ok {
 					continue
 				}
@@ -64,18 +74,99 @@ func GetInsertionOrder(tablesToFieldsMap map[string][]types.FieldDescriptor) ([]
 				break
 			}
 			if canInsert {
-				newInsertCount++
-				tablesVisited[table] = struct{}{}
-				tablesVisitOrder = append(tablesVisitOrder, table)
+				candidates = append(candidates, table)
 			}
 		}
-		if newInsertCount == 0 {
+		if len(candidates) == 0 {
 			return nil, errors.New("error generating insertion order. Maybe necessary dependencies are not met")
 		}
+		sort.Strings(candidates)
+		for _, table := range candidates {
+			tablesVisited[table] = struct{}{}
+		}
+		tablesVisitOrder = append(tablesVisitOrder, candidates...)
 	}
 	return tablesVisitOrder, nil
 }
 
+// InsertTargets returns the deduplicated insertable tables of the discovered
+// relations in their original order
+func InsertTargets(tables []types.Table) []string {
+	var targets []string
+	seen := make(map[string]struct{})
+	for _, table := range tables {
+		target, ok := table.InsertTarget()
+		if !ok {
+			continue
+		}
+		if _, ok := seen[target]; ok {
+			continue
+		}
+		seen[target] = struct{}{}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// CountRows returns the number of rows in the table
+func CountRows(ctx context.Context, table string, db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+	return count, err
+}
+
+// DeleteOrder returns the tables in the reverse of the insertion order, the
+// referencing tables come before the referenced ones
+func DeleteOrder(tables, insertionOrder []string) []string {
+	selected := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		selected[table] = struct{}{}
+	}
+	order := make([]string, 0, len(tables))
+	for i := len(insertionOrder) - 1; i >= 0; i-- {
+		if _, ok := selected[insertionOrder[i]]; ok {
+			order = append(order, insertionOrder[i])
+			delete(selected, insertionOrder[i])
+		}
+	}
+	// Tables missing from the insertion order are not referenced
+	for _, table := range tables {
+		if _, ok := selected[table]; ok {
+			order = append(order, table)
+		}
+	}
+	return order
+}
+
+// DeleteAll deletes every row of the tables one by one in the given order
+func DeleteAll(ctx context.Context, tables []string, db *sql.DB) error {
+	for _, table := range tables {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", table)); err != nil {
+			return fmt.Errorf("utils: delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// ClassifyCommonError classifies the driver independent errors, the
+// drivers fall back to it for the errors they don't know
+func ClassifyCommonError(err error) types.ErrorCategory {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return types.TimeoutError
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return types.ConnectionError
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return types.TimeoutError
+		}
+		return types.ConnectionError
+	}
+	return types.OtherError
+}
+
 func TestTable(db *sql.DB, testCase, table string, d types.Testable) error {
 	test, err := d.GetTestCase(testCase)
 	if err != nil {
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+func TestDeleteOrder(t *testing.T) {
+	var scenarios = []struct {
+		tables         []string
+		insertionOrder []string
+		output         []string
+	}{
+		{
+			tables:         []string{"users", "orders", "order_items"},
+			insertionOrder: []string{"users", "products", "orders", "order_items"},
+			output:         []string{"order_items", "orders", "users"},
+		},
+		{
+			tables:         []string{"users", "audit"},
+			insertionOrder: []string{"users"},
+			output:         []string{"users", "audit"},
+		},
+		{
+			tables:         nil,
+			insertionOrder: []string{"users"},
+			output:         []string{},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		output := DeleteOrder(scenario.tables, scenario.insertionOrder)
+		if !reflect.DeepEqual(output, scenario.output) {
+			t.Errorf("Invalid delete order, out: %v expected: %v", output, scenario.output)
+		}
+	}
+}
+
+func TestInsertTargets(t *testing.T) {
+	tables := []types.Table{
+		{Name: "users", Kind: types.BaseTable},
+		{Name: "active_users", Kind: types.View},
+		{Name: "user_stats", Kind: types.MaterializedView},
+		{Name: "measurements", Kind: types.PartitionedTable},
+		{Name: "measurements_2020", Kind: types.Partition, Parent: "measurements"},
+		{Name: "measurements_2021", Kind: types.Partition, Parent: "measurements"},
+		{Name: "remote_orders", Kind: types.ForeignTable},
+		{Name: "events_2021", Kind: types.Partition, Parent: "events"},
+	}
+
+	output := InsertTargets(tables)
+	expected := []string{"users", "measurements", "events"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("Invalid insert targets, out: %v expected: %v", output, expected)
+	}
+}
+
+func TestGetInsertionOrder(t *testing.T) {
+	fk := func(table, column string) *types.FKDescriptor {
+		return &types.FKDescriptor{ForeignTableName: table, ForeignColumnName: column}
+	}
+	tableToFields := map[string][]types.FieldDescriptor{
+		"orders":    {{Field: "id"}, {Field: "user_id", ForeignKeyDescriptor: fk("users", "id")}},
+		"employees": {{Field: "id"}, {Field: "manager_id", ForeignKeyDescriptor: fk("employees", "id")}},
+		"users":     {{Field: "id"}, {Field: "referrer_id", ForeignKeyDescriptor: fk("users", "id")}},
+		"addresses": {{Field: "id"}, {Field: "user_id", ForeignKeyDescriptor: fk("users", "id")}},
+	}
+
+	expected := []string{"employees", "users", "addresses", "orders"}
+	// The map iteration order is random, the insertion order should not be.
+	for i := 0; i < 10; i++ {
+		order, err := GetInsertionOrder(tableToFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(order, expected) {
+			t.Fatalf("Invalid insertion order, out: %v expected: %v", order, expected)
+		}
+	}
+}
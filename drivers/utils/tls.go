@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// TLS modes of the connection, the names follow the sslmode of Postgres
+const (
+	TLSDisable    = "disable"
+	TLSRequire    = "require"
+	TLSVerifyCA   = "verify-ca"
+	TLSVerifyFull = "verify-full"
+)
+
+// TLSConfig builds the client TLS config of the flags, it returns nil if
+// TLS is disabled. The server certificate is not verified in require mode,
+// its chain is verified against the CA bundle in verify-ca mode and the
+// host name is checked as well in verify-full mode.
+func TLSConfig(f types.Flags) (*tls.Config, error) {
+	switch f.TLSMode {
+	case "", TLSDisable:
+		return nil, nil
+	case TLSRequire, TLSVerifyCA, TLSVerifyFull:
+	default:
+		return nil, fmt.Errorf("utils: unknown tls mode: %s", f.TLSMode)
+	}
+	// The server name is set by the driver from the address
+	config := &tls.Config{}
+	if f.TLSCert != "" || f.TLSKey != "" {
+		if f.TLSCert == "" || f.TLSKey == "" {
+			return nil, errors.New("utils: both the client certificate and key should be set")
+		}
+		cert, err := tls.LoadX509KeyPair(f.TLSCert, f.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("utils: invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if f.TLSCA != "" {
+		pem, err := ioutil.ReadFile(f.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("utils: invalid ca bundle: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("utils: no certificate in the ca bundle %s", f.TLSCA)
+		}
+	}
+	switch f.TLSMode {
+	case TLSRequire:
+		config.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// The host name is not checked, the chain is verified by the callback
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	}
+	return config, nil
+}
+
+// verifyChain verifies the certificate chain of the server against the
+// roots without checking the host name
+func verifyChain(roots *x509.CertPool) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("utils: no server certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
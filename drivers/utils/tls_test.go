@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// testCert creates a certificate signed by the parent, self-signed if the
+// parent is nil
+func testCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := testCert(t, "ca", nil, nil)
+	server, _ := testCert(t, "db.local", ca, caKey)
+	client, clientKey := testCert(t, "sqlfuzz", ca, caKey)
+	otherCA, otherCAKey := testCert(t, "other", nil, nil)
+	otherServer, _ := testCert(t, "db.local", otherCA, otherCAKey)
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(dir, "ca.pem")
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	writePEM(t, caPath, "CERTIFICATE", ca.Raw)
+	writePEM(t, certPath, "CERTIFICATE", client.Raw)
+	writePEM(t, keyPath, "EC PRIVATE KEY", clientKeyDER)
+
+	var scenarios = []struct {
+		flags types.Flags
+		nil   bool
+		err   bool
+		check func(t *testing.T, flags types.Flags)
+	}{
+		{flags: types.Flags{}, nil: true},
+		{flags: types.Flags{TLSMode: TLSDisable}, nil: true},
+		{flags: types.Flags{TLSMode: "prefer"}, err: true},
+		{flags: types.Flags{TLSMode: TLSRequire, TLSCert: certPath}, err: true},
+		{flags: types.Flags{TLSMode: TLSVerifyFull, TLSCA: certPath + ".missing"}, err: true},
+		{flags: types.Flags{TLSMode: TLSVerifyFull, TLSCA: keyPath}, err: true},
+		{
+			flags: types.Flags{TLSMode: TLSRequire, TLSCert: certPath, TLSKey: keyPath},
+			check: func(t *testing.T, flags types.Flags) {
+				config, _ := TLSConfig(flags)
+				if !config.InsecureSkipVerify || len(config.Certificates) != 1 {
+					t.Errorf("Invalid require config: %+v", config)
+				}
+			},
+		},
+		{
+			flags: types.Flags{TLSMode: TLSVerifyCA, TLSCA: caPath},
+			check: func(t *testing.T, flags types.Flags) {
+				config, _ := TLSConfig(flags)
+				if err := config.VerifyPeerCertificate([][]byte{server.Raw}, nil); err != nil {
+					t.Errorf("Server certificate signed by the CA should be accepted: %v", err)
+				}
+				if err := config.VerifyPeerCertificate([][]byte{otherServer.Raw}, nil); err == nil {
+					t.Error("Server certificate signed by other CA should be rejected")
+				}
+			},
+		},
+		{
+			flags: types.Flags{TLSMode: TLSVerifyFull, TLSCA: caPath},
+			check: func(t *testing.T, flags types.Flags) {
+				config, _ := TLSConfig(flags)
+				if config.InsecureSkipVerify || config.RootCAs == nil {
+					t.Errorf("Invalid verify-full config: %+v", config)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		config, err := TLSConfig(scenario.flags)
+		if (err != nil) != scenario.err {
+			t.Errorf("Unexpected error for %+v: %v", scenario.flags, err)
+			continue
+		}
+		if err == nil && (config == nil) != scenario.nil {
+			t.Errorf("Invalid config for %+v: %+v", scenario.flags, config)
+		}
+		if scenario.check != nil {
+			scenario.check(t, scenario.flags)
+		}
+	}
+}
@@ -1,47 +1,313 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/utils"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/connector"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/filter"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/flags"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/fuzzer"
-	"github.com/brianvoe/gofakeit/v5"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/journal"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/metrics"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/report"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// Exit codes of the process
+const (
+	exitOK          = 0
+	exitFailed      = 1
+	exitInterrupted = 130
+)
+
 func main() {
-	f := flags.Get()
-	gofakeit.Seed(int64(f.Seed))
-	driver := drivers.New(f.Driver)
-	db := connector.Connection(driver, f)
+	os.Exit(execute(flags.Get()))
+}
+
+// execute runs the command of the flags and returns the exit code
+func execute(f flags.Flags) int {
+	switch f.Command {
+	case flags.CommandFill:
+		return fuzz(f)
+	case flags.CommandDescribe:
+		return describe(f)
+	case flags.CommandPlan:
+		return plan(f)
+	case flags.CommandDump:
+		return dumpRows(f)
+	case flags.CommandClean:
+		return clean(f)
+	}
+	log.Printf("unknown command: %s", f.Command)
+	return exitFailed
+}
+
+// fuzz runs the fuzzing, prints the summary and returns the exit code
+func fuzz(f flags.Flags) int {
+	driver, db := connect(f)
 	defer db.Close()
+	ctx, cancel := signalContext()
+	defer cancel()
 
-	var tables []string
-	if f.Table == "" {
-		var err error
-		tables, err = driver.ShowTables(db)
-		if err != nil {
-			log.Print(err)
-			return
+	f.Seed = resolveSeed(f.Seed)
+	collector := stats.New()
+	var runReport *report.Report
+	if f.Report != "" {
+		runReport = report.New(driver.Driver(), f.Driver.Database, f.Seed)
+	}
+	if f.MetricsAddr != "" {
+		server := serveMetrics(f.MetricsAddr, collector, driver.Driver())
+		defer stopMetrics(ctx, server, f.MetricsLinger)
+	}
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	go collector.ReportProgress(progressCtx, f.Progress)
+	err := run(ctx, f, driver, db, collector, runReport)
+	stopProgress()
+	summary := collector.Summary()
+	if summaryErr := printSummary(f.SummaryFormat, summary); summaryErr != nil {
+		log.Print(summaryErr)
+	}
+	if runReport != nil {
+		runReport.Finish(summary, err)
+		if reportErr := runReport.Write(f.Report); reportErr != nil {
+			log.Print(reportErr)
 		}
-	} else {
-		tables = []string{f.Table}
 	}
+	return exitCode(err, "Fuzzing interrupted")
+}
+
+// resolveSeed returns the seed of the run, the time based seed is resolved
+// once so the report records the seed reproducing the rows
+func resolveSeed(seed int) int {
+	if seed == 0 {
+		return int(time.Now().UnixNano())
+	}
+	return seed
+}
+
+// clean deletes the rows recorded in the journal and removes the journal
+// if every row was deleted, it returns the exit code
+func clean(f flags.Flags) int {
+	if f.Journal == "" {
+		log.Print("the journal file should be set by -journal")
+		return exitFailed
+	}
+	entries, err := journal.Read(f.Journal)
+	if err != nil {
+		log.Print(err)
+		return exitFailed
+	}
+	driver, db := connect(f)
+	defer db.Close()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	deleted, err := journal.Clean(ctx, driver, db, entries)
+	log.Printf("Deleted %d of the %d journaled rows", deleted, len(entries))
+	if err == nil {
+		err = os.Remove(f.Journal)
+	}
+	return exitCode(err, "Cleaning interrupted")
+}
+
+// exitCode logs the error and returns the exit code of it
+func exitCode(err error, interrupted string) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Print(interrupted)
+		return exitInterrupted
+	case err != nil:
+		log.Print(err.Error())
+		return exitFailed
+	}
+	return exitOK
+}
+
+// connect creates the driver and the connection of the flags
+func connect(f flags.Flags) (types.Driver, *sql.DB) {
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return driver, connector.Connection(driver, f)
+}
+
+// signalContext returns a context canceled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancelOnSignal(cancel)
+	return ctx, cancel
+}
+
+// printSummary logs the summary in text format or writes it to the
+// standard output in json format
+func printSummary(format string, summary stats.Summary) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	case "text":
+		log.Print(summary)
+		return nil
+	}
+	return fmt.Errorf("unknown summary format: %s", format)
+}
+
+// truncate empties the tables before the fuzzing. The rows are deleted in
+// the reverse of the insertion order unless the foreign keys are ignored by
+// the truncate-cascade flag.
+func truncate(ctx context.Context, f flags.Flags, driver types.Driver, db *sql.DB, tables, insertionOrder []string) error {
+	if f.TruncateCascade {
+		log.Printf("Truncating %v ignoring the foreign keys", tables)
+		return driver.Truncate(ctx, tables, db)
+	}
+	order := utils.DeleteOrder(tables, insertionOrder)
+	log.Printf("Deleting the rows of %v", order)
+	return utils.DeleteAll(ctx, order, db)
+}
+
+// serveMetrics exposes the metrics of the collector on /metrics
+func serveMetrics(addr string, collector *stats.Collector, driver string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(collector, driver))
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("metrics: %v", err)
+		}
+	}()
+	return server
+}
+
+// stopMetrics keeps serving the final metrics for the linger period or
+// until the context is canceled by a signal, then closes the server
+func stopMetrics(ctx context.Context, server *http.Server, linger time.Duration) {
+	if linger > 0 && ctx.Err() == nil {
+		log.Printf("Serving the final metrics for %v", linger)
+		timer := time.NewTimer(linger)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if err := server.Close(); err != nil {
+		log.Printf("metrics: %v", err)
+	}
+}
+
+// cancelOnSignal cancels the run on SIGINT or SIGTERM, a second signal
+// kills the process
+func cancelOnSignal(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	signal.Stop(signals)
+	log.Printf("Received %v, stopping the workers", sig)
+	cancel()
+}
+
+// selectTables returns the chosen table or the insertable discovered tables
+// matching the include and exclude patterns
+func selectTables(ctx context.Context, f flags.Flags, driver types.Driver, db *sql.DB) ([]string, error) {
+	if f.Table != "" {
+		return []string{f.Table}, nil
+	}
+	discovered, err := driver.ShowTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	tableFilter, err := filter.New(f.Include, f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return filterTargets(discovered, tableFilter), nil
+}
+
+// filterTargets returns the insert targets of the discovered tables matching
+// the filter, the partitions are filtered by the name of their parent
+func filterTargets(discovered []types.Table, tableFilter filter.Filter) []string {
+	return tableFilter.Apply(utils.InsertTargets(discovered))
+}
+
+// describeTables describes the tables and returns them in insertion order,
+// the referenced tables are described as well but they are not part of the
+// insertion order unless they are selected
+func describeTables(ctx context.Context, driver types.Driver, db *sql.DB,
+	tables []string) (map[string][]types.FieldDescriptor, []string, error) {
+	tableToFields, insertionOrder, err := driver.MultiDescribe(ctx, tables, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected := make(map[string]struct{}, len(tables))
 	for _, table := range tables {
+		selected[table] = struct{}{}
+	}
+	order := make([]string, 0, len(tables))
+	for _, table := range insertionOrder {
+		if _, ok := selected[table]; ok {
+			order = append(order, table)
+		}
+	}
+	return tableToFields, order, nil
+}
+
+// run fuzzes the chosen or discovered tables one by one in insertion order,
+// or all of them concurrently until the duration is over
+func run(ctx context.Context, f flags.Flags, driver types.Driver, db *sql.DB, collector *stats.Collector,
+	runReport *report.Report) error {
+	tables, err := selectTables(ctx, f, driver, db)
+	if err != nil {
+		return err
+	}
+	tableToFields, insertionOrder, err := describeTables(ctx, driver, db, tables)
+	if err != nil {
+		return err
+	}
+	if f.Truncate || f.TruncateCascade {
+		if err := truncate(ctx, f, driver, db, tables, insertionOrder); err != nil {
+			return err
+		}
+	}
+	if f.Duration > 0 {
+		for _, table := range insertionOrder {
+			runReport.AddTable(table, tableToFields[table])
+		}
+		return fuzzer.RunFor(ctx, tableToFields, insertionOrder, f, collector)
+	}
+	num, targetRows := f.Num, f.TargetRows
+	for _, table := range insertionOrder {
 		f.Table = table
-		fields, err := driver.Describe(f.Table, db)
+		if targetRows > 0 {
+			f.TargetRows, err = f.Rows.Resolve(table, targetRows)
+		} else {
+			f.Num, err = f.Rows.Resolve(table, num)
+		}
 		if err != nil {
-			log.Print(err.Error())
-			return
+			return err
 		}
+		fields := tableToFields[table]
+		runReport.AddTable(table, fields)
 		t := time.Now()
-		if err := fuzzer.Run(fields, f); err != nil {
-			log.Print(err.Error())
-			return
+		if err := fuzzer.Run(ctx, fields, f, collector); err != nil {
+			return err
 		}
 		log.Printf("Fuzzing %s table taken: %v \n", table, time.Since(t))
 	}
+	return nil
 }
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/connector"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/dump"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/filter"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/flags"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/fuzzer"
-	"github.com/brianvoe/gofakeit/v5"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/schema"
+	"github.com/volatiletech/null"
 )
 
 const (
@@ -32,9 +45,14 @@ func TestFuzz(t *testing.T) {
 	f.Workers = 2
 	f.Seed = 1
 
-	gofakeit.Seed(int64(f.Seed))
-	driver := drivers.New(f.Driver)
-	testable := drivers.NewTestable(f.Driver)
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testable, err := drivers.NewTestable(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
 	db := connector.Connection(driver, f)
 	defer db.Close()
 	if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", f.Table)); err != nil {
@@ -43,11 +61,11 @@ func TestFuzz(t *testing.T) {
 	if err := testable.TestTable(db, "single", f.Table); err != nil {
 		t.Fatal(err)
 	}
-	fields, err := driver.Describe(f.Table, db)
+	fields, err := driver.Describe(context.Background(), f.Table, db)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
-	err = fuzzer.Run(fields, f)
+	err = fuzzer.Run(context.Background(), fields, f, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,9 +108,14 @@ func TestFuzzPostgres(t *testing.T) {
 	f.Workers = 2
 	f.Seed = 1
 
-	gofakeit.Seed(int64(f.Seed))
-	driver := drivers.New(f.Driver)
-	testable := drivers.NewTestable(f.Driver)
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testable, err := drivers.NewTestable(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
 	db := connector.Connection(driver, f)
 	defer db.Close()
 	if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", f.Table)); err != nil {
@@ -101,11 +124,11 @@ func TestFuzzPostgres(t *testing.T) {
 	if err := testable.TestTable(db, "single", f.Table); err != nil {
 		t.Fatal(err)
 	}
-	fields, err := driver.Describe(f.Table, db)
+	fields, err := driver.Describe(context.Background(), f.Table, db)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
-	err = fuzzer.Run(fields, f)
+	err = fuzzer.Run(context.Background(), fields, f, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -158,9 +181,14 @@ func TestMysqlMultiInsert(t *testing.T) {
 	f.Workers = 2
 	f.Seed = 1
 
-	gofakeit.Seed(int64(f.Seed))
-	driver := drivers.New(f.Driver)
-	testable := drivers.NewTestable(f.Driver)
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testable, err := drivers.NewTestable(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
 	test, err := testable.GetTestCase("multi")
 	if err != nil {
 		t.Error(fmt.Sprintf("postgres : error fetching test case for multi. %v", err.Error()))
@@ -174,11 +202,11 @@ func TestMysqlMultiInsert(t *testing.T) {
 		t.Fatal(err)
 	}
 	tables := test.TableCreationOrder
-	tableFieldMap, insertionOrder, err := driver.MultiDescribe(tables, db)
+	tableFieldMap, insertionOrder, err := driver.MultiDescribe(context.Background(), tables, db)
 	if err != nil {
 		t.Errorf("Error describing tables %v. Error %v", tables, err)
 	}
-	err = fuzzer.RunMulti(tableFieldMap, insertionOrder, f)
+	err = fuzzer.RunMulti(context.Background(), tableFieldMap, insertionOrder, f, nil)
 	if err != nil {
 		t.Errorf("error during multi insert %v", err.Error())
 	}
@@ -200,9 +228,14 @@ func TestPostgresMultiInsert(t *testing.T) {
 	f.Workers = 2
 	f.Seed = 1
 
-	gofakeit.Seed(int64(f.Seed))
-	driver := drivers.New(f.Driver)
-	testable := drivers.NewTestable(f.Driver)
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testable, err := drivers.NewTestable(f.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
 	test, err := testable.GetTestCase("multi")
 	if err != nil {
 		t.Error(fmt.Sprintf("postgres : error fetching test case for multi. %v", err.Error()))
@@ -216,11 +249,11 @@ func TestPostgresMultiInsert(t *testing.T) {
 		t.Fatal(err)
 	}
 	tables := test.TableCreationOrder
-	tableFieldMap, insertionOrder, err := driver.MultiDescribe(tables, db)
+	tableFieldMap, insertionOrder, err := driver.MultiDescribe(context.Background(), tables, db)
 	if err != nil {
 		t.Errorf("Error describing tables %v. Error %v", tables, err)
 	}
-	err = fuzzer.RunMulti(tableFieldMap, insertionOrder, f)
+	err = fuzzer.RunMulti(context.Background(), tableFieldMap, insertionOrder, f, nil)
 	if err != nil {
 		t.Errorf("error during multi insert %v", err.Error())
 	}
@@ -261,3 +294,210 @@ func warpErr(err error, msg string) error {
 	}
 	return fmt.Errorf(msg)
 }
+
+func TestFilterTargets(t *testing.T) {
+	discovered := []types.Table{
+		{Name: "users", Kind: types.BaseTable},
+		{Name: "measurements", Kind: types.PartitionedTable},
+		{Name: "measurements_2020", Kind: types.Partition, Parent: "measurements"},
+		{Name: "measurements_2021", Kind: types.Partition, Parent: "measurements"},
+	}
+	var scenarios = []struct {
+		include []string
+		exclude []string
+		output  []string
+	}{
+		{
+			output: []string{"users", "measurements"},
+		},
+		{
+			exclude: []string{"measurements"},
+			output:  []string{"users"},
+		},
+		{
+			include: []string{"measurements_*"},
+			output:  nil,
+		},
+		{
+			include: []string{"measurements"},
+			output:  []string{"measurements"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		tableFilter, err := filter.New(scenario.include, scenario.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := filterTargets(discovered, tableFilter)
+		if !reflect.DeepEqual(output, scenario.output) {
+			t.Errorf("Invalid targets of %v/%v, out: %v expected: %v", scenario.include, scenario.exclude, output, scenario.output)
+		}
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	driver, err := drivers.New(types.Flags{Driver: "mysql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableToFields := map[string][]types.FieldDescriptor{
+		"users": {
+			{Field: "id", Type: "int", HasDefaultValue: true},
+			{Field: "name", Type: "varchar", Length: null.IntFrom(30)},
+		},
+		"orders": {
+			{Field: "user_id", Type: "int", ForeignKeyDescriptor: &types.FKDescriptor{ForeignTableName: "users", ForeignColumnName: "id"}},
+		},
+	}
+	var b bytes.Buffer
+	err = printPlan(&b, driver, tableToFields, []string{"users", "orders"}, map[string]int{"users": 10, "orders": 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Insertion order: users, orders\n",
+		"users (10 rows)\n",
+		"database default\n",
+		"string(30)\n",
+		"orders (200 rows)\n",
+		"foreign key users.id\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Missing %q from the plan:\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestDumpSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	snapshot := schema.Snapshot{
+		Driver:         "postgres",
+		InsertionOrder: []string{"users", "orders", "audit"},
+		Tables: map[string][]types.FieldDescriptor{
+			"users": {
+				{Field: "id", Type: "integer", HasDefaultValue: true},
+				{Field: "name", Type: "character varying", Length: null.IntFrom(30)},
+			},
+			"orders": {
+				{Field: "user_id", Type: "integer", ForeignKeyDescriptor: &types.FKDescriptor{ForeignTableName: "users", ForeignColumnName: "id"}},
+			},
+			"audit": {{Field: "message", Type: "text"}},
+		},
+	}
+	f := flags.Flags{
+		Snapshot:   filepath.Join(dir, "schema.json"),
+		Output:     filepath.Join(dir, "rows.sql"),
+		DumpFormat: dump.SQL,
+		Num:        3,
+		Seed:       1,
+		Exclude:    flags.Patterns{"audit"},
+	}
+	if err := schema.Write(f.Snapshot, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := dumpRows(f); code != exitOK {
+		t.Fatalf("Dump from snapshot failed with exit code %d", code)
+	}
+	rows, err := ioutil.ReadFile(f.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(rows)), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], `INSERT INTO users("id","name")`) || !strings.HasPrefix(lines[3], `INSERT INTO orders("user_id")`) {
+		t.Fatalf("Invalid rows generated from the snapshot:\n%s", rows)
+	}
+	userIDs := make(map[string]struct{})
+	for _, line := range lines[:3] {
+		userIDs[regexp.MustCompile(`VALUES\((\d+),`).FindStringSubmatch(line)[1]] = struct{}{}
+	}
+	for _, line := range lines[3:] {
+		userID := regexp.MustCompile(`VALUES\((.*)\);`).FindStringSubmatch(line)[1]
+		if _, ok := userIDs[userID]; !ok {
+			t.Errorf("Foreign key %s of %q is not a generated user", userID, line)
+		}
+	}
+
+	f.Table = "missing"
+	if _, _, err := loadSchema(context.Background(), f); err == nil {
+		t.Error("Table missing from the snapshot should fail")
+	}
+}
+
+func TestDumpSnapshotSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	snapshot := schema.Snapshot{
+		Driver:         "mysql",
+		InsertionOrder: []string{"users"},
+		Tables: map[string][]types.FieldDescriptor{
+			"users": {
+				{Field: "email", Type: "varchar", Length: null.IntFrom(255)},
+				{Field: "age", Type: "int"},
+				{Field: "created", Type: "datetime"},
+			},
+		},
+	}
+	path := filepath.Join(dir, "schema.json")
+	if err := schema.Write(path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(output string) string {
+		f := flags.Flags{Snapshot: path, Output: filepath.Join(dir, output), DumpFormat: dump.JSON, Num: 5, Seed: 7}
+		if code := dumpRows(f); code != exitOK {
+			t.Fatalf("Dump from snapshot failed with exit code %d", code)
+		}
+		rows, err := ioutil.ReadFile(f.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(rows)
+	}
+	first, second := generate("first.json"), generate("second.json")
+	if first != second {
+		t.Errorf("The same snapshot and seed should generate the same rows:\n%s\n%s", first, second)
+	}
+}
+
+func TestResolveSeed(t *testing.T) {
+	if seed := resolveSeed(42); seed != 42 {
+		t.Errorf("Invalid seed, out: %d expected: 42", seed)
+	}
+	if seed := resolveSeed(0); seed == 0 {
+		t.Error("The time based seed should be resolved")
+	}
+}
+
+func TestStopMetrics(t *testing.T) {
+	var scenarios = []struct {
+		canceled bool
+		linger   time.Duration
+		min      time.Duration
+	}{
+		{linger: 0},
+		{linger: 50 * time.Millisecond, min: 50 * time.Millisecond},
+		{linger: time.Hour, canceled: true},
+	}
+
+	for _, scenario := range scenarios {
+		ctx, cancel := context.WithCancel(context.Background())
+		if scenario.canceled {
+			cancel()
+		}
+		start := time.Now()
+		stopMetrics(ctx, &http.Server{}, scenario.linger)
+		if elapsed := time.Since(start); elapsed < scenario.min || elapsed > scenario.min+time.Second {
+			t.Errorf("Invalid linger of %v, stopped after %v", scenario.linger, elapsed)
+		}
+		cancel()
+	}
+}
@@ -2,21 +2,18 @@
 package action
 
 import (
+	"context"
 	"database/sql"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
-	"github.com/brianvoe/gofakeit/v5"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
 	_ "github.com/lib/pq"
-	"github.com/rs/xid"
 )
 
 type SingleInsertParams struct {
@@ -31,78 +28,252 @@ type MultiInsertParams struct {
 	Driver           types.Driver
 	InsertionOrder   []string
 	TableToFieldsMap map[string][]types.FieldDescriptor
+	// TableToRowCount is the number of rows of each table spread across the
+	// NumJobs jobs of the run. Tables without count get a row in every job.
+	TableToRowCount map[string]int
+	NumJobs         int
 }
 
 type SQLInsertInput struct {
 	SingleInsertParams *SingleInsertParams
 	MultiInsertParams  *MultiInsertParams
+	// Stats collects the outcome of the inserts per table, optional
+	Stats *stats.Collector
+	// Journal records the primary keys of the inserted rows, optional
+	Journal Recorder
+	// Rand generates the values of the rows, it should be safe for
+	// concurrent use (see generator.NewRand)
+	Rand *rand.Rand
+	// Generators generate the values of the columns, the built-in
+	// generators are used if it is nil
+	Generators *generator.Registry
+	// BeforeInsert is called with every generated row before its insert,
+	// optional. It can change the columns and values of the row, skip it
+	// by returning ErrSkipRow or fail the insert by any other error. The
+	// retries generate new rows, so it is called again with fresh values
+	// on every attempt.
+	BeforeInsert func(ctx context.Context, row *Row) error
+	// AfterInsert is called after the insert statement of every row with
+	// the primary key of the row (nil if unknown) and the error of the
+	// statement, optional. In multi-table jobs the transaction of the row
+	// can still be rolled back by the insert of the next tables. It is
+	// called on every attempt, a retried insert reports the error of each
+	// failed attempt before the final outcome.
+	AfterInsert func(ctx context.Context, row Row, key map[string]interface{}, err error)
+	// QueryTimeout limits every attempt of the insert, 0 means no timeout
+	QueryTimeout time.Duration
+	Retry        RetryPolicy
 }
 
-func (sqlInsertInput SQLInsertInput) Insert() error {
+// ErrSkipRow is returned by BeforeInsert to skip the insert of the row
+var ErrSkipRow = errors.New("action: row skipped")
+
+// Row is a generated row passed to the insert hooks
+type Row struct {
+	Table   string
+	Columns []string
+	Values  []interface{}
+}
+
+// Recorder records the primary keys of the inserted rows, it is
+// implemented by the journals
+type Recorder interface {
+	Record(table string, key map[string]interface{}) error
+}
+
+// InsertError is returned when the insert into a table failed
+type InsertError struct {
+	Table string
+	Err   error
+}
+
+func (e *InsertError) Error() string {
+	return fmt.Sprintf("action: insert into %s: %v", e.Table, e.Err)
+}
+
+func (e *InsertError) Unwrap() error {
+	return e.Err
+}
+
+// insertedRow is a row inserted into the table and the latency of its
+// statement
+type insertedRow struct {
+	table string
+	took  time.Duration
+	// key is the primary key of the row, nil if it is unknown
+	key map[string]interface{}
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Insert inserts a random generated row. The job is the sequence number of
+// the insert in the run, used to schedule the per-table row counts.
+// Transient errors are retried based on the retry policy.
+func (sqlInsertInput SQLInsertInput) Insert(ctx context.Context, job int) error {
+	if sqlInsertInput.SingleInsertParams == nil && sqlInsertInput.MultiInsertParams == nil {
+		return errors.New("action: error in sql insert input. Both single and multi insert arguments are not initialized")
+	}
+	var inserted []insertedRow
+	var err error
+	for attempt := 0; ; attempt++ {
+		inserted, err = sqlInsertInput.insert(ctx, job)
+		if err == nil || attempt >= sqlInsertInput.Retry.Attempts || !sqlInsertInput.retryable(err) {
+			break
+		}
+		var insertErr *InsertError
+		if errors.As(err, &insertErr) {
+			sqlInsertInput.Stats.RecordRetry(insertErr.Table)
+		}
+		if !sqlInsertInput.Retry.wait(ctx, attempt) {
+			break
+		}
+	}
+	sqlInsertInput.record(ctx, inserted, err)
+	return err
+}
+
+// insert makes a single attempt of the insert
+func (sqlInsertInput SQLInsertInput) insert(ctx context.Context, job int) ([]insertedRow, error) {
+	if sqlInsertInput.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sqlInsertInput.QueryTimeout)
+		defer cancel()
+	}
 	if sqlInsertInput.SingleInsertParams != nil {
-		return sqlInsertInput.singleInsert()
-	} else if sqlInsertInput.MultiInsertParams != nil {
-		return sqlInsertInput.multiInsert()
+		return sqlInsertInput.singleInsert(ctx)
+	}
+	return sqlInsertInput.multiInsert(ctx, job)
+}
+
+// Rows returns the number of rows inserted by the job
+func (sqlInsertInput SQLInsertInput) Rows(job int) int {
+	if sqlInsertInput.MultiInsertParams == nil {
+		return 1
 	}
-	return errors.New("action: error in sql insert input. Both single and multi insert arguments are not initialized")
+	return len(sqlInsertInput.MultiInsertParams.scheduledTables(job))
 }
 
-func (sqlInsertInput SQLInsertInput) multiInsert() error {
+// multiInsert inserts a row into every scheduled table of the job in a
+// single transaction, the transaction is rolled back on any error. It
+// returns the inserted rows.
+func (sqlInsertInput SQLInsertInput) multiInsert(ctx context.Context, job int) ([]insertedRow, error) {
 	multiInsertParams := sqlInsertInput.MultiInsertParams
 	if multiInsertParams == nil {
-		return errors.New("action : error during multi insert. Could not find necessary arguments")
+		return nil, errors.New("action : error during multi insert. Could not find necessary arguments")
 	}
-	tableFieldValuesMap := make(map[string]map[string]interface{})
-	for _, table := range multiInsertParams.InsertionOrder {
-		fields, ok := multiInsertParams.TableToFieldsMap[table]
-		if !ok {
-			continue
+	tables := multiInsertParams.scheduledTables(job)
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	tx, err := multiInsertParams.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, &InsertError{Table: tables[0], Err: err}
+	}
+	rollback := func(table string, err error) ([]insertedRow, error) {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			log.Print(rollbackErr)
 		}
+		return nil, &InsertError{Table: table, Err: err}
+	}
 
+	inserted := make([]insertedRow, 0, len(tables))
+	tableFieldValuesMap := make(map[string]map[string]interface{})
+	for _, table := range tables {
+		fields := multiInsertParams.TableToFieldsMap[table]
 		var f = make([]string, 0, len(fields))
-		var values []interface{}
+		var values = make([]interface{}, 0, len(fields))
 		for _, field := range fields {
-			f = append(f, field.Field)
 			if field.HasDefaultValue {
 				continue
 			}
-
-			if field.ForeignKeyDescriptor == nil {
-				values = append(values, generateData(multiInsertParams.Driver, field))
-				continue
-			}
-
-			if foreignTableFields, ok := tableFieldValuesMap[field.ForeignKeyDescriptor.ForeignTableName]; ok {
-				if val, ok := foreignTableFields[field.ForeignKeyDescriptor.ForeignColumnName]; ok {
-					values = append(values, val)
-					continue // TODO this can be an error
-				}
-			}
-			val, err := multiInsertParams.Driver.GetLatestColumnValue(
-				field.ForeignKeyDescriptor.ForeignTableName,
-				field.ForeignKeyDescriptor.ForeignColumnName,
-				multiInsertParams.DB,
-			)
+			val, err := sqlInsertInput.fieldValue(ctx, multiInsertParams.Driver, multiInsertParams.DB, table, field, tableFieldValuesMap)
 			if err != nil {
-				return err
+				return rollback(table, err)
 			}
+			f = append(f, field.Field)
 			values = append(values, val)
-			// Get from table. If no value present in table as well, throw error.
 		}
-		query := multiInsertParams.Driver.Insert(f, table)
-		_, err := multiInsertParams.DB.Exec(query, values...)
+		row, generated, err := sqlInsertInput.hookedInsertRow(ctx, tx, multiInsertParams.Driver, table, fields, f, values)
+		if errors.Is(err, ErrSkipRow) {
+			continue
+		}
 		if err != nil {
-			return err
+			return rollback(table, err)
+		}
+		inserted = append(inserted, row)
+		fieldValues := make(map[string]interface{}, len(generated.Columns))
+		for i, column := range generated.Columns {
+			fieldValues[column] = generated.Values[i]
 		}
+		// Keys filled by the database can be referenced by the next tables
+		for column, val := range row.key {
+			if _, ok := fieldValues[column]; !ok {
+				fieldValues[column] = val
+			}
+		}
+		tableFieldValuesMap[table] = fieldValues
+	}
+	if err := tx.Commit(); err != nil {
+		return rollback(tables[len(tables)-1], err)
 	}
-	return nil
+	return inserted, nil
+}
+
+// scheduledTables returns the tables get a row in the job in insertion order
+func (multiInsertParams *MultiInsertParams) scheduledTables(job int) []string {
+	var tables []string
+	for _, table := range multiInsertParams.InsertionOrder {
+		if _, ok := multiInsertParams.TableToFieldsMap[table]; !ok {
+			continue
+		}
+		if multiInsertParams.scheduled(table, job) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// scheduled reports whether the table gets a row in the job, the rows of
+// the table are spread evenly between the jobs
+func (multiInsertParams *MultiInsertParams) scheduled(table string, job int) bool {
+	count, ok := multiInsertParams.TableToRowCount[table]
+	if !ok || multiInsertParams.NumJobs <= 0 {
+		return true
+	}
+	job %= multiInsertParams.NumJobs
+	return (job+1)*count/multiInsertParams.NumJobs > job*count/multiInsertParams.NumJobs
+}
+
+// fieldValue generates the value of the field. Foreign keys are taken from
+// the row inserted into the referenced table in the same job, or from the
+// latest row of the referenced table.
+func (sqlInsertInput SQLInsertInput) fieldValue(ctx context.Context, driver types.Driver, db *sql.DB, table string,
+	field types.FieldDescriptor, tableFieldValuesMap map[string]map[string]interface{}) (interface{}, error) {
+	if field.ForeignKeyDescriptor == nil {
+		return sqlInsertInput.Generators.Generate(sqlInsertInput.rand(), driver, table, field)
+	}
+	if foreignTableFields, ok := tableFieldValuesMap[field.ForeignKeyDescriptor.ForeignTableName]; ok {
+		if val, ok := foreignTableFields[field.ForeignKeyDescriptor.ForeignColumnName]; ok {
+			return val, nil
+		}
+	}
+	return driver.GetLatestColumnValue(
+		ctx,
+		field.ForeignKeyDescriptor.ForeignTableName,
+		field.ForeignKeyDescriptor.ForeignColumnName,
+		db,
+	)
 }
 
 // singleInsert is inserting a random generated data into the chosen table
-func (sqlInsertInput SQLInsertInput) singleInsert() error {
+func (sqlInsertInput SQLInsertInput) singleInsert(ctx context.Context) ([]insertedRow, error) {
 	insertParams := sqlInsertInput.SingleInsertParams
 	if insertParams == nil {
-		return errors.New("action : error during insert. Could not find necessary arguments")
+		return nil, errors.New("action : error during insert. Could not find necessary arguments")
 	}
 	var f = make([]string, 0, len(insertParams.Fields))
 	var values = make([]interface{}, 0, len(insertParams.Fields))
@@ -111,117 +282,173 @@ func (sqlInsertInput SQLInsertInput) singleInsert() error {
 		if field.HasDefaultValue {
 			continue
 		}
+		val, err := sqlInsertInput.fieldValue(ctx, insertParams.Driver, insertParams.DB, insertParams.Table, field, nil)
+		if err != nil {
+			return nil, &InsertError{Table: insertParams.Table, Err: err}
+		}
 		f = append(f, field.Field)
-		values = append(values, generateData(insertParams.Driver, field))
+		values = append(values, val)
+	}
+	row, _, err := sqlInsertInput.hookedInsertRow(ctx, insertParams.DB, insertParams.Driver, insertParams.Table, insertParams.Fields, f, values)
+	if errors.Is(err, ErrSkipRow) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &InsertError{Table: insertParams.Table, Err: err}
 	}
-	query := insertParams.Driver.Insert(f, insertParams.Table)
+	return []insertedRow{row}, nil
+}
 
-	_, err := insertParams.DB.Exec(query, values...)
-	return err
+// hookedInsertRow inserts the row between the BeforeInsert and AfterInsert
+// hooks, it returns the row as it was changed by BeforeInsert. ErrSkipRow
+// is returned if the row was skipped by BeforeInsert.
+func (sqlInsertInput SQLInsertInput) hookedInsertRow(ctx context.Context, q queryer, driver types.Driver, table string,
+	fields []types.FieldDescriptor, columns []string, values []interface{}) (insertedRow, Row, error) {
+	generated := Row{Table: table, Columns: columns, Values: values}
+	if sqlInsertInput.BeforeInsert != nil {
+		if err := sqlInsertInput.BeforeInsert(ctx, &generated); err != nil {
+			return insertedRow{table: table}, generated, err
+		}
+		if len(generated.Columns) != len(generated.Values) {
+			return insertedRow{table: table}, generated, fmt.Errorf("action: %d columns and %d values after BeforeInsert",
+				len(generated.Columns), len(generated.Values))
+		}
+	}
+	row, err := insertRow(ctx, q, driver, table, fields, generated.Columns, generated.Values)
+	if sqlInsertInput.AfterInsert != nil {
+		sqlInsertInput.AfterInsert(ctx, generated, row.key, err)
+	}
+	return row, generated, err
 }
 
-// generateData generates random data based on the field
-func generateData(driver types.Driver, fieldDescriptor types.FieldDescriptor) interface{} {
-	field := driver.MapField(fieldDescriptor)
-	switch field.Type {
-	case types.String:
-		if field.Length > 19 {
-			return xid.New().String()
-		}
-		if field.Length > 0 {
-			return randomString(field.Length)
-		}
-		return randomString(20)
-	case types.Int16:
-		return gofakeit.Number(1, 32766)
-	case types.Int32:
-		return gofakeit.Number(1, 2147483647)
-	case types.Float:
-		max := 2147483647
-		if fieldDescriptor.Precision.Valid && fieldDescriptor.Scale.Valid {
-			max = int(math.Pow10(fieldDescriptor.Precision.Int - fieldDescriptor.Scale.Int))
-		}
-		return gofakeit.Number(1, max)
-	case types.Blob:
-		return base64.StdEncoding.EncodeToString([]byte(randomString(12)))
-	case types.Text:
-		return randomString(12)
-	case types.Enum:
-		return field.Enum[gofakeit.Number(0, len(field.Enum)-1)]
-	case types.Bool:
-		if gofakeit.Number(1, 200)%2 == 0 {
-			return true
-		}
-		return false
-	case types.Json:
-		return fmt.Sprintf(
-			`{"%s": "%s", "%s": "%s"}`,
-			gofakeit.Password(true, true, false, false, false, 6),
-			gofakeit.Password(true, true, false, false, false, 6),
-			gofakeit.Password(true, true, false, false, false, 6),
-			gofakeit.Password(true, true, false, false, false, 6),
-		)
-	case types.Time:
-		return time.Date(
-			gofakeit.Number(1980, 2028),
-			time.Month(gofakeit.Number(0, 12)),
-			gofakeit.Day(),
-			gofakeit.Hour(),
-			gofakeit.Minute(),
-			gofakeit.Second(),
-			gofakeit.NanoSecond(),
-			time.UTC)
-	case types.Year:
-		return gofakeit.Number(1901, 2155)
-	case types.XML:
-		xml, err := gofakeit.XML(&gofakeit.XMLOptions{
-			Type:          "single",
-			RootElement:   "xml",
-			RecordElement: "record",
-			RowCount:      2,
-			Indent:        true,
-			Fields: []gofakeit.Field{
-				{Name: "first_name", Function: "firstname"},
-				{Name: "last_name", Function: "lastname"},
-				{Name: "password", Function: "password", Params: map[string][]string{"special": {"false"}}},
-			},
-		})
-		if err != nil {
-			return nil
+// insertRow inserts the values into the columns of the table and returns
+// the row with its primary key. The key columns filled by the database are
+// returned by the insert if the driver supports it, otherwise a single one
+// is taken from the last insert id.
+func insertRow(ctx context.Context, q queryer, driver types.Driver, table string, fields []types.FieldDescriptor,
+	columns []string, values []interface{}) (insertedRow, error) {
+	row := insertedRow{table: table}
+	key, generated := primaryKey(fields, columns, values)
+	query := driver.Insert(columns, table)
+	returning := ""
+	if len(generated) > 0 {
+		returning = driver.Returning(generated)
+	}
+
+	start := time.Now()
+	if returning != "" {
+		returned := make([]interface{}, len(generated))
+		dest := make([]interface{}, len(generated))
+		for i := range returned {
+			dest[i] = &returned[i]
 		}
-		return string(xml)
-	case types.UUID:
-		return gofakeit.UUID()
-	case types.BinaryString:
-		return binaryString(int(field.Length))
-	case types.Unknown:
-		log.Printf("unknown field type: %s (%s)\n", fieldDescriptor.Field, fieldDescriptor.Type)
-		return nil
+		if err := q.QueryRowContext(ctx, query+returning, values...).Scan(dest...); err != nil {
+			return row, err
+		}
+		row.took = time.Since(start)
+		for i, column := range generated {
+			key[column] = keyValue(returned[i])
+		}
+		row.key = key
+		return row, nil
+	}
+	result, err := q.ExecContext(ctx, query, values...)
+	if err != nil {
+		return row, err
 	}
+	row.took = time.Since(start)
+	if len(generated) > 0 {
+		id, err := result.LastInsertId()
+		if len(generated) > 1 || err != nil {
+			return row, nil
+		}
+		key[generated[0]] = id
+	}
+	row.key = key
+	return row, nil
+}
 
-	return nil
+// primaryKey returns the values of the primary key columns of the row and
+// the key columns left to the database, the key is nil if the table has no
+// primary key
+func primaryKey(fields []types.FieldDescriptor, columns []string, values []interface{}) (map[string]interface{}, []string) {
+	var key map[string]interface{}
+	var generated []string
+	for _, field := range fields {
+		if field.Key != "PRI" {
+			continue
+		}
+		if key == nil {
+			key = make(map[string]interface{})
+		}
+		found := false
+		for i, column := range columns {
+			if column == field.Field {
+				key[column] = keyValue(values[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			generated = append(generated, field.Field)
+		}
+	}
+	return key, generated
 }
 
-// randomString generates a length size random string
-func randomString(length int16) string {
-	var charset = "abcdefghijklmnopqrstuvwxyz" +
-		"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// keyValue converts the raw bytes returned by the database to string to
+// keep the key readable in the journal
+func keyValue(val interface{}) interface{} {
+	if b, ok := val.([]byte); ok {
+		return string(b)
+	}
+	return val
+}
 
-	var seededRand = rand.New(
-		rand.NewSource(time.Now().UnixNano()))
+// retryable reports whether the error is transient and the insert should
+// be retried, the connection errors only if the insert was not sent
+func (sqlInsertInput SQLInsertInput) retryable(err error) bool {
+	switch sqlInsertInput.driver().ClassifyError(err) {
+	case types.TransientError:
+		return true
+	case types.ConnectionError:
+		return notSent(err)
+	}
+	return false
+}
 
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+// record records the outcome of the insert. The inserts interrupted by
+// the cancellation of the run are not counted.
+func (sqlInsertInput SQLInsertInput) record(ctx context.Context, inserted []insertedRow, err error) {
+	for _, row := range inserted {
+		sqlInsertInput.Stats.Record(row.table, row.took, "", nil)
+		if row.key == nil || sqlInsertInput.Journal == nil {
+			continue
+		}
+		if err := sqlInsertInput.Journal.Record(row.table, row.key); err != nil {
+			log.Print(err)
+		}
+	}
+	var insertErr *InsertError
+	if err == nil || !errors.As(err, &insertErr) || errors.Is(ctx.Err(), context.Canceled) {
+		return
 	}
+	sqlInsertInput.Stats.Record(insertErr.Table, 0, sqlInsertInput.driver().ClassifyError(insertErr.Err), insertErr.Err)
+}
 
-	return string(b)
+// rand returns the random generator of the input, a time seeded one if it
+// is not set
+func (sqlInsertInput SQLInsertInput) rand() *rand.Rand {
+	if sqlInsertInput.Rand == nil {
+		return generator.NewRand(0)
+	}
+	return sqlInsertInput.Rand
 }
 
-func binaryString(length int) string {
-	var str []string
-	for i := 0; i < length; i++ {
-		str = append(str, strconv.Itoa(gofakeit.Number(0, 1)))
+// driver returns the driver of the insert params
+func (sqlInsertInput SQLInsertInput) driver() types.Driver {
+	if sqlInsertInput.SingleInsertParams != nil {
+		return sqlInsertInput.SingleInsertParams.Driver
 	}
-	return strings.Join(str, "")
+	return sqlInsertInput.MultiInsertParams.Driver
 }
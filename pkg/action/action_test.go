@@ -0,0 +1,167 @@
+package action
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/mysql"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+func TestMultiInsertScheduled(t *testing.T) {
+	params := MultiInsertParams{
+		TableToRowCount: map[string]int{"users": 1000, "orders": 20000, "empty": 0},
+		NumJobs:         20000,
+	}
+	counts := make(map[string]int)
+	for job := 0; job < params.NumJobs; job++ {
+		for _, table := range []string{"users", "orders", "empty", "unknown"} {
+			if params.scheduled(table, job) {
+				counts[table]++
+			}
+		}
+	}
+	expected := map[string]int{"users": 1000, "orders": 20000, "unknown": 20000}
+	for table, count := range expected {
+		if counts[table] != count {
+			t.Errorf("Invalid number of rows for %s, out: %d expected: %d", table, counts[table], count)
+		}
+	}
+	if counts["empty"] != 0 {
+		t.Errorf("Table with 0 rows should not be scheduled, out: %d", counts["empty"])
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	scenarios := []struct {
+		fields            []types.FieldDescriptor
+		columns           []string
+		values            []interface{}
+		expectedKey       map[string]interface{}
+		expectedGenerated []string
+	}{
+		{
+			fields:      []types.FieldDescriptor{{Field: "id", Key: "PRI"}, {Field: "name"}},
+			columns:     []string{"id", "name"},
+			values:      []interface{}{12, "john"},
+			expectedKey: map[string]interface{}{"id": 12},
+		},
+		{
+			fields:            []types.FieldDescriptor{{Field: "id", Key: "PRI", HasDefaultValue: true}, {Field: "name"}},
+			columns:           []string{"name"},
+			values:            []interface{}{"john"},
+			expectedKey:       map[string]interface{}{},
+			expectedGenerated: []string{"id"},
+		},
+		{
+			fields:      []types.FieldDescriptor{{Field: "user_id", Key: "PRI"}, {Field: "sku", Key: "PRI"}},
+			columns:     []string{"user_id", "sku"},
+			values:      []interface{}{3, []byte("abc")},
+			expectedKey: map[string]interface{}{"user_id": 3, "sku": "abc"},
+		},
+		{
+			fields:  []types.FieldDescriptor{{Field: "name"}},
+			columns: []string{"name"},
+			values:  []interface{}{"john"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		key, generated := primaryKey(scenario.fields, scenario.columns, scenario.values)
+		if !reflect.DeepEqual(key, scenario.expectedKey) {
+			t.Errorf("Invalid key, out: %v expected: %v", key, scenario.expectedKey)
+		}
+		if !reflect.DeepEqual(generated, scenario.expectedGenerated) {
+			t.Errorf("Invalid generated columns, out: %v expected: %v", generated, scenario.expectedGenerated)
+		}
+	}
+}
+
+// execRecorder is a queryer recording the arguments of the statements
+type execRecorder struct {
+	args [][]interface{}
+	err  error
+}
+
+func (e *execRecorder) ExecContext(_ context.Context, _ string, args ...interface{}) (sql.Result, error) {
+	e.args = append(e.args, args)
+	return driver.RowsAffected(1), e.err
+}
+
+func (e *execRecorder) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestHookedInsertRow(t *testing.T) {
+	insertErr := errors.New("duplicate")
+	var scenarios = []struct {
+		before   func(ctx context.Context, row *Row) error
+		execErr  error
+		args     [][]interface{}
+		err      error
+		afterErr error
+		after    int
+	}{
+		{
+			args:  [][]interface{}{{"john", 30}},
+			after: 1,
+		},
+		{
+			before: func(_ context.Context, row *Row) error {
+				row.Values[0] = "jane"
+				row.Columns = append(row.Columns, "role")
+				row.Values = append(row.Values, "admin")
+				return nil
+			},
+			args:  [][]interface{}{{"jane", 30, "admin"}},
+			after: 1,
+		},
+		{
+			before: func(context.Context, *Row) error { return ErrSkipRow },
+			err:    ErrSkipRow,
+		},
+		{
+			before: func(_ context.Context, row *Row) error {
+				row.Columns = row.Columns[:1]
+				return nil
+			},
+			err: errors.New("mismatch"),
+		},
+		{
+			execErr:  insertErr,
+			args:     [][]interface{}{{"john", 30}},
+			err:      insertErr,
+			afterErr: insertErr,
+			after:    1,
+		},
+	}
+
+	for i, scenario := range scenarios {
+		q := &execRecorder{err: scenario.execErr}
+		after := 0
+		input := SQLInsertInput{
+			BeforeInsert: scenario.before,
+			AfterInsert: func(_ context.Context, row Row, _ map[string]interface{}, err error) {
+				after++
+				if err != scenario.afterErr {
+					t.Errorf("Invalid error of AfterInsert in scenario %d: %v", i, err)
+				}
+			},
+		}
+		_, _, err := input.hookedInsertRow(context.Background(), q, mysql.New(types.Flags{}), "users", nil,
+			[]string{"name", "age"}, []interface{}{"john", 30})
+		if (err == nil) != (scenario.err == nil) || (errors.Is(scenario.err, ErrSkipRow) && !errors.Is(err, ErrSkipRow)) {
+			t.Errorf("Unexpected error in scenario %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(q.args, scenario.args) {
+			t.Errorf("Invalid arguments in scenario %d, out: %v expected: %v", i, q.args, scenario.args)
+		}
+		if after != scenario.after {
+			t.Errorf("AfterInsert called %d times in scenario %d, expected: %d", after, i, scenario.after)
+		}
+	}
+}
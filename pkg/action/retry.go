@@ -0,0 +1,64 @@
+package action
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures the retries of the inserts failed with transient
+// errors like deadlocks, lock wait timeouts, serialization failures and
+// connections failed before the insert was sent. The connections lost
+// after the insert was sent are not retried, the row may be inserted.
+type RetryPolicy struct {
+	// Attempts is the maximum number of retries, 0 disables retrying
+	Attempts int
+	// Backoff is the delay before the first retry, doubled on every retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between the retries
+	MaxBackoff time.Duration
+}
+
+// delay returns the exponential backoff of the attempt with jitter, the
+// result is between the half and the full backoff
+func (r RetryPolicy) delay(attempt int) time.Duration {
+	backoff := r.Backoff
+	for i := 0; i < attempt && (r.MaxBackoff <= 0 || backoff < r.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+// wait sleeps before the next retry, it returns false if the context is
+// done in the meantime
+func (r RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	timer := time.NewTimer(r.delay(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// notSent reports whether the connection failed before the statement was
+// sent to the server, so it can't have been applied
+func notSent(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,54 @@
+package action
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	r := RetryPolicy{Attempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	var scenarios = []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, scenario := range scenarios {
+		for i := 0; i < 20; i++ {
+			if delay := r.delay(scenario.attempt); delay < scenario.min || delay > scenario.max {
+				t.Errorf("Invalid delay for attempt %d, out: %v expected between %v and %v",
+					scenario.attempt, delay, scenario.min, scenario.max)
+			}
+		}
+	}
+}
+
+func TestNotSent(t *testing.T) {
+	var scenarios = []struct {
+		err    error
+		output bool
+	}{
+		{driver.ErrBadConn, true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("insert: %w", &net.OpError{Op: "dial", Err: errors.New("no such host")}), true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, false},
+		{io.ErrUnexpectedEOF, false},
+	}
+
+	for _, scenario := range scenarios {
+		if output := notSent(scenario.err); output != scenario.output {
+			t.Errorf("Invalid not sent of %v, out: %v expected: %v", scenario.err, output, scenario.output)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+)
+
+// Formats of the dump
+const (
+	SQL  = "sql"
+	JSON = "json"
+)
+
+// Row is a generated row of the json format
+type Row struct {
+	Table string                 `json:"table"`
+	Row   map[string]interface{} `json:"row"`
+}
+
+// Dump generates the rows of the tables in insertion order and writes them
+// as insert statements (sql) or json lines (json). Foreign keys take the
+// values of the rows generated for the referenced tables. The referenced
+// columns with default value get explicit values, integer keys are numbered
+// from 1 without advancing the sequences of the database. The other columns
+// with default value are left to the database. The values are generated by
+// rng.
+func Dump(w io.Writer, format string, rng *rand.Rand, driver types.Driver, tableToFields map[string][]types.FieldDescriptor,
+	insertionOrder []string, tableToRowCount map[string]int) error {
+	if format != SQL && format != JSON {
+		return fmt.Errorf("dump: unknown format: %s", format)
+	}
+	b := bufio.NewWriter(w)
+	encoder := json.NewEncoder(b)
+	referenced := referencedColumns(tableToFields)
+	generated := make(map[string][]map[string]interface{})
+	for _, table := range insertionOrder {
+		fields := tableToFields[table]
+		for i := 0; i < tableToRowCount[table]; i++ {
+			columns, values, err := generateRow(rng, driver, table, i, fields, referenced[table], generated)
+			if err != nil {
+				return err
+			}
+			if refColumns, ok := referenced[table]; ok {
+				generated[table] = append(generated[table], pick(columns, values, refColumns))
+			}
+			if format == SQL {
+				_, err = fmt.Fprintln(b, Statement(driver, table, columns, values))
+			} else {
+				err = encoder.Encode(Row{Table: table, Row: pick(columns, values, nil)})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// generateRow generates the values of the columns without default value
+// and of the referenced columns, row is the index of the row in the table
+func generateRow(rng *rand.Rand, driver types.Driver, table string, row int, fields []types.FieldDescriptor,
+	refColumns map[string]struct{}, generated map[string][]map[string]interface{}) ([]string, []interface{}, error) {
+	columns := make([]string, 0, len(fields))
+	values := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		_, referenced := refColumns[field.Field]
+		if field.HasDefaultValue && !referenced {
+			continue
+		}
+		var val interface{}
+		var err error
+		if field.HasDefaultValue {
+			val, err = referencedValue(rng, driver, table, row, field)
+		} else {
+			val, err = fieldValue(rng, driver, table, field, generated)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, field.Field)
+		values = append(values, val)
+	}
+	return columns, values, nil
+}
+
+// fieldValue generates the value of the field, foreign keys are taken from
+// a random row generated for the referenced table
+func fieldValue(rng *rand.Rand, driver types.Driver, table string, field types.FieldDescriptor,
+	generated map[string][]map[string]interface{}) (interface{}, error) {
+	if fk := field.ForeignKeyDescriptor; fk != nil {
+		if rows := generated[fk.ForeignTableName]; len(rows) > 0 {
+			if val, ok := rows[rng.Intn(len(rows))][fk.ForeignColumnName]; ok {
+				return val, nil
+			}
+		}
+	}
+	return generator.Generate(rng, driver, table, field)
+}
+
+// referencedValue generates the value of the referenced column with default
+// value, the integer keys are numbered from 1 to stay unique
+func referencedValue(rng *rand.Rand, driver types.Driver, table string, row int, field types.FieldDescriptor) (interface{}, error) {
+	switch driver.MapField(field).Type {
+	case types.Int16, types.Int32:
+		return row + 1, nil
+	}
+	return generator.Generate(rng, driver, table, field)
+}
+
+// referencedColumns returns the columns referenced by foreign keys per table
+func referencedColumns(tableToFields map[string][]types.FieldDescriptor) map[string]map[string]struct{} {
+	referenced := make(map[string]map[string]struct{})
+	for _, fields := range tableToFields {
+		for _, field := range fields {
+			if fk := field.ForeignKeyDescriptor; fk != nil {
+				if referenced[fk.ForeignTableName] == nil {
+					referenced[fk.ForeignTableName] = make(map[string]struct{})
+				}
+				referenced[fk.ForeignTableName][fk.ForeignColumnName] = struct{}{}
+			}
+		}
+	}
+	return referenced
+}
+
+// pick returns the values of the selected columns, every column if the
+// selection is nil
+func pick(columns []string, values []interface{}, selected map[string]struct{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if _, ok := selected[column]; ok || selected == nil {
+			row[column] = values[i]
+		}
+	}
+	return row
+}
+
+// Statement returns the insert statement of the driver with the values
+// formatted as SQL literals
+func Statement(driver types.Driver, table string, columns []string, values []interface{}) string {
+	query := driver.Insert(columns, table)
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literals = append(literals, Literal(driver.Driver(), value))
+	}
+	if i := strings.LastIndex(query, "VALUES("); i >= 0 {
+		query = query[:i]
+	}
+	return query + "VALUES(" + strings.Join(literals, ",") + ");"
+}
+
+// Literal formats the value as an SQL literal of the driver
+func Literal(driver string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return quote(driver, v.Format("2006-01-02 15:04:05.999999"))
+	case []byte:
+		return quote(driver, string(v))
+	case string:
+		return quote(driver, v)
+	}
+	return quote(driver, fmt.Sprint(value))
+}
+
+// quote quotes the string, MySQL treats the backslash as escape character
+func quote(driver, s string) string {
+	if driver == "mysql" {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
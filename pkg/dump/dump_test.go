@@ -0,0 +1,115 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/mysql"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/postgres"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+	"github.com/volatiletech/null"
+)
+
+func TestLiteral(t *testing.T) {
+	var scenarios = []struct {
+		driver string
+		input  interface{}
+		output string
+	}{
+		{"mysql", nil, "NULL"},
+		{"mysql", true, "TRUE"},
+		{"postgres", false, "FALSE"},
+		{"mysql", 42, "42"},
+		{"mysql", int64(-7), "-7"},
+		{"mysql", 1.5, "1.5"},
+		{"mysql", "it's", "'it''s'"},
+		{"mysql", `a\b`, `'a\\b'`},
+		{"postgres", `a\b`, `'a\b'`},
+		{"postgres", []byte("abc"), "'abc'"},
+		{"postgres", time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC), "'2020-01-02 03:04:05.000006'"},
+	}
+
+	for _, scenario := range scenarios {
+		if output := Literal(scenario.driver, scenario.input); output != scenario.output {
+			t.Errorf("Invalid literal for %v, out: %s expected: %s", scenario.input, output, scenario.output)
+		}
+	}
+}
+
+func TestStatement(t *testing.T) {
+	var scenarios = []struct {
+		driver types.Driver
+		output string
+	}{
+		{mysql.New(types.Flags{Driver: "mysql"}), "INSERT INTO users(`id`,`name`) VALUES(1,'john');"},
+		{postgres.New(types.Flags{Driver: "postgres"}), `INSERT INTO users("id","name") VALUES(1,'john');`},
+	}
+
+	for _, scenario := range scenarios {
+		output := Statement(scenario.driver, "users", []string{"id", "name"}, []interface{}{1, "john"})
+		if output != scenario.output {
+			t.Errorf("Invalid statement, out: %s expected: %s", output, scenario.output)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	driver := mysql.New(types.Flags{Driver: "mysql"})
+	tableToFields := map[string][]types.FieldDescriptor{
+		"users": {
+			{Field: "id", Type: "int"},
+			{Field: "created_at", Type: "timestamp", HasDefaultValue: true},
+		},
+		"orders": {
+			{Field: "id", Type: "int"},
+			{Field: "user_id", Type: "int", ForeignKeyDescriptor: &types.FKDescriptor{ForeignTableName: "users", ForeignColumnName: "id"}},
+			{Field: "note", Type: "varchar", Length: null.IntFrom(10)},
+		},
+	}
+	counts := map[string]int{"users": 3, "orders": 10}
+
+	var b bytes.Buffer
+	if err := Dump(&b, JSON, generator.NewRand(1), driver, tableToFields, []string{"users", "orders"}, counts); err != nil {
+		t.Fatal(err)
+	}
+	userIDs := make(map[interface{}]struct{})
+	rows := 0
+	decoder := json.NewDecoder(&b)
+	for decoder.More() {
+		var row Row
+		if err := decoder.Decode(&row); err != nil {
+			t.Fatal(err)
+		}
+		rows++
+		switch row.Table {
+		case "users":
+			if _, ok := row.Row["created_at"]; ok {
+				t.Error("Columns with default value should be left to the database")
+			}
+			userIDs[row.Row["id"]] = struct{}{}
+		case "orders":
+			if _, ok := userIDs[row.Row["user_id"]]; !ok {
+				t.Errorf("Foreign key %v is not a generated user", row.Row["user_id"])
+			}
+		}
+	}
+	if rows != 13 {
+		t.Errorf("Invalid number of rows, out: %d expected: 13", rows)
+	}
+
+	b.Reset()
+	if err := Dump(&b, SQL, generator.NewRand(1), driver, tableToFields, []string{"users"}, counts); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(b.String(), "INSERT INTO users(`id`) VALUES("); lines != 3 {
+		t.Errorf("Invalid sql dump:\n%s", b.String())
+	}
+
+	if err := Dump(&b, "csv", generator.NewRand(1), driver, tableToFields, nil, nil); err == nil {
+		t.Error("Error expected for unknown format")
+	}
+}
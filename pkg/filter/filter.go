@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexpPrefix marks a pattern as a regular expression instead of a glob
+const RegexpPrefix = "re:"
+
+type matcher func(table string) bool
+
+// Filter selects tables by include and exclude patterns
+type Filter struct {
+	include []matcher
+	exclude []matcher
+}
+
+// New builds a Filter. Patterns are globs (app_*, audit_?) unless they are
+// prefixed with "re:", in that case they are treated as regular expressions
+func New(include, exclude []string) (Filter, error) {
+	var f Filter
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return Filter{}, err
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return Filter{}, err
+	}
+	return f, nil
+}
+
+// Match reports whether the table is selected by the filter. A table is
+// selected if it matches any include pattern (or there are none) and
+// doesn't match any exclude pattern
+func (f Filter) Match(table string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, table) {
+		return false
+	}
+	return !matchAny(f.exclude, table)
+}
+
+// Apply returns the selected tables keeping their original order
+func (f Filter) Apply(tables []string) []string {
+	var selected []string
+	for _, table := range tables {
+		if f.Match(table) {
+			selected = append(selected, table)
+		}
+	}
+	return selected
+}
+
+func matchAny(matchers []matcher, table string) bool {
+	for _, m := range matchers {
+		if m(table) {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(patterns []string) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		m, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func compilePattern(pattern string) (matcher, error) {
+	if strings.HasPrefix(pattern, RegexpPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, RegexpPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid regular expression %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("filter: invalid glob pattern %q: %w", pattern, err)
+	}
+	return func(table string) bool {
+		ok, _ := path.Match(pattern, table)
+		return ok
+	}, nil
+}
@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+	tables := []string{"app_users", "app_orders", "schema_migrations", "flyway_schema_history", "audit_log", "app_audit"}
+	var scenarios = []struct {
+		include []string
+		exclude []string
+		output  []string
+	}{
+		{
+			output: tables,
+		},
+		{
+			include: []string{"app_*"},
+			output:  []string{"app_users", "app_orders", "app_audit"},
+		},
+		{
+			include: []string{"app_*"},
+			exclude: []string{"*audit*"},
+			output:  []string{"app_users", "app_orders"},
+		},
+		{
+			exclude: []string{"schema_migrations", "flyway_schema_history", "re:^audit_"},
+			output:  []string{"app_users", "app_orders", "app_audit"},
+		},
+		{
+			include: []string{"re:orders$", "app_user?"},
+			output:  []string{"app_users", "app_orders"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		f, err := New(scenario.include, scenario.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := f.Apply(tables)
+		if !reflect.DeepEqual(output, scenario.output) {
+			t.Errorf("Invalid output for include %v exclude %v, out: %v", scenario.include, scenario.exclude, output)
+		}
+	}
+}
+
+func TestFilterInvalidPattern(t *testing.T) {
+	if _, err := New([]string{"app_["}, nil); err == nil {
+		t.Error("invalid glob should return an error")
+	}
+	if _, err := New(nil, []string{"re:app_("}); err == nil {
+		t.Error("invalid regular expression should return an error")
+	}
+}
@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// Config is the layout of the file passed by the -config flag
+//
+//	{
+//	  "rows": {"users": 1000, "orders:users": 20},
+//	  "types": {"citext": "text", "geometry": "string(64)"}
+//	}
+type Config struct {
+	Rows map[string]float64 `json:"rows"`
+	// Types maps raw column types to fields, see types.ParseField
+	Types map[string]string `json:"types"`
+}
+
+// LoadConfig reads and parses the config file
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("flags: invalid config file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// apply sets the config values into the flags, values set from the CLI
+// take precedence over the file
+func (c Config) apply(f *Flags) error {
+	var rows Rows
+	for key, value := range c.Rows {
+		if err := rows.Add(key, value); err != nil {
+			return err
+		}
+	}
+	rows.Merge(f.Rows)
+	f.Rows = rows
+	for rawType, name := range c.Types {
+		field, err := types.ParseField(name)
+		if err != nil {
+			return fmt.Errorf("flags: invalid type of %s: %w", rawType, err)
+		}
+		if f.Driver.Types == nil {
+			f.Driver.Types = make(map[string]types.Field)
+		}
+		f.Driver.Types[rawType] = field
+	}
+	return nil
+}
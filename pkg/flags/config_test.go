@@ -0,0 +1,67 @@
+package flags
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+func TestConfigTypes(t *testing.T) {
+	var scenarios = []struct {
+		types  map[string]string
+		output map[string]types.Field
+		err    bool
+	}{
+		{
+			types: map[string]string{"citext": "text", "geometry": "string(64)", "mood": "enum(happy, sad)", "tsvector": "binary string"},
+			output: map[string]types.Field{
+				"citext":   {Type: types.Text, Length: -1},
+				"geometry": {Type: types.String, Length: 64},
+				"mood":     {Type: types.Enum, Length: -1, Enum: []string{"happy", "sad"}},
+				"tsvector": {Type: types.BinaryString, Length: -1},
+			},
+		},
+		{types: map[string]string{"money": "decimal"}, err: true},
+		{types: map[string]string{"ltree": "string(long)"}, err: true},
+	}
+
+	for _, scenario := range scenarios {
+		var f Flags
+		err := Config{Types: scenario.types}.apply(&f)
+		if (err != nil) != scenario.err {
+			t.Errorf("Unexpected error for %v: %v", scenario.types, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(f.Driver.Types, scenario.output) {
+			t.Errorf("Invalid types, out: %+v expected: %+v", f.Driver.Types, scenario.output)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"types": {"citext": "text"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{CommandFill, CommandDescribe, CommandPlan, CommandDump} {
+		f, err := Parse([]string{command, "-config", path}, &bytes.Buffer{})
+		if err != nil {
+			t.Errorf("Config should be accepted by %s: %v", command, err)
+			continue
+		}
+		if f.Driver.Types["citext"].Type != types.Text {
+			t.Errorf("Invalid types of %s: %+v", command, f.Driver.Types)
+		}
+	}
+}
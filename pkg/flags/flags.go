@@ -1,26 +1,113 @@
 package flags
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/PumpkinSeed/sqlfuzz/drivers/mysql"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/utils"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 )
 
 var f Flags
 
+// EnvPrefix is the prefix of the environment variables setting the default
+// of the flags, e.g. SQLFUZZ_PASSWORD sets -password
+const EnvPrefix = "SQLFUZZ_"
+
+// lookupEnv returns the value of the environment variable
+var lookupEnv = os.LookupEnv
+
+// Commands of the CLI, the command is the first argument
+const (
+	CommandFill     = "fill"
+	CommandDescribe = "describe"
+	CommandPlan     = "plan"
+	CommandDump     = "dump"
+	CommandClean    = "clean"
+)
+
+// command is a subcommand of the CLI and the flag groups it accepts
+type command struct {
+	name        string
+	description string
+	groups      []func(fs *flagSet, f *Flags)
+}
+
+var commands = []command{
+	{
+		name:        CommandFill,
+		description: "Fill the tables with random rows (default command)",
+		groups:      []func(fs *flagSet, f *Flags){connectionFlags, tableFlags, configFlags, rowFlags, fillFlags},
+	},
+	{
+		name:        CommandDescribe,
+		description: "Print the described columns of the tables and the fields they are mapped to",
+		groups:      []func(fs *flagSet, f *Flags){connectionFlags, tableFlags, configFlags, describeFlags},
+	},
+	{
+		name:        CommandPlan,
+		description: "Print the insertion order, the number of rows and the generator of each column",
+		groups:      []func(fs *flagSet, f *Flags){connectionFlags, tableFlags, configFlags, rowFlags, snapshotFlags},
+	},
+	{
+		name:        CommandDump,
+		description: "Generate the rows into a file instead of inserting them",
+		groups:      []func(fs *flagSet, f *Flags){connectionFlags, tableFlags, configFlags, rowFlags, dumpFlags, snapshotFlags},
+	},
+	{
+		name:        CommandClean,
+		description: "Delete the rows recorded in the journal",
+		groups:      []func(fs *flagSet, f *Flags){connectionFlags, cleanFlags},
+	},
+}
+
 // Flags represents the CLI flags
 type Flags struct {
-	Driver types.Flags
+	// Command is the command of the CLI, fill if it is not set
+	Command string
+	Driver  types.Flags
 
-	Num     int
-	Workers int
-	Table   string
+	Num        int
+	Rows       Rows
+	TargetRows int
+	TargetSize ByteSize
+	Duration   time.Duration
+	Rate       float64
+	Workers    int
+	Table      string
+	Include    Patterns
+	Exclude    Patterns
 
+	QueryTimeout         time.Duration
+	MaxErrorRate         float64
+	Retries              int
+	RetryBackoff         time.Duration
+	RetryMaxBackoff      time.Duration
+	SummaryFormat        string
+	Progress             time.Duration
+	MetricsAddr          string
+	MetricsLinger        time.Duration
+	Report               string
+	Journal              string
+	Truncate             bool
+	TruncateCascade      bool
+	Output               string
+	DumpFormat           string
+	Snapshot             string
 	ConnMaxLifetimeInSec time.Duration
 	MaxIdleConns         int
 	MaxOpenConns         int
 	Seed                 int
+	ConfigFile           string
 
 	Parsed bool
 }
@@ -34,24 +121,345 @@ func Get() Flags {
 	return f
 }
 
-// parse parsing the flags into the f variable
+// parse parsing the command line into the f variable
 func parse() {
-	if !f.Parsed {
-		flag.StringVar(&f.Driver.Username, "u", "test", "Username for the database connection")
-		flag.StringVar(&f.Driver.Password, "p", "test", "Password for the database connection")
-		flag.StringVar(&f.Driver.Database, "d", "test", "Database of the database connection")
-		flag.StringVar(&f.Driver.Host, "h", "localhost", "Host for the database connection")
-		flag.StringVar(&f.Driver.Port, "P", "3306", "Port for the database connection")
-		flag.StringVar(&f.Driver.Driver, "D", "mysql", "Driver for the database connection (mysql, postgres, etc.)")
-		flag.StringVar(&f.Table, "t", "", "Table for fuzzing")
-		flag.IntVar(&f.Num, "n", 1000, "Number of rows")
-		flag.IntVar(&f.Workers, "w", 20, "Number of workers")
-		flag.IntVar(&f.MaxIdleConns, "i", 200, "Number of max sql db idle connections")
-		flag.IntVar(&f.MaxOpenConns, "o", 1000, "Number of max sql db open connections")
-		flag.IntVar(&f.Seed, "s", 0, "Seed value for reproducibility")
-		flag.DurationVar(&f.ConnMaxLifetimeInSec, "l", 100*time.Second, "Maximum lifetime of each open connection")
-		flag.Parse()
+	parsed, err := Parse(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	f = parsed
+}
+
+// Parse parses the command and its flags from the arguments, the command
+// is fill if the first argument is a flag. The help is written to the
+// output and flag.ErrHelp is returned when it is requested.
+func Parse(args []string, output io.Writer) (Flags, error) {
+	var f Flags
+	f.Command = CommandFill
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		f.Command, args = args[0], args[1:]
+	}
+	if f.Command == "help" {
+		if len(args) == 0 {
+			usage(output)
+			return f, flag.ErrHelp
+		}
+		f.Command, args = args[0], []string{"-help"}
+	}
+	c, ok := lookupCommand(f.Command)
+	if !ok {
+		usage(output)
+		return f, fmt.Errorf("flags: unknown command %s", f.Command)
+	}
+
+	fs := newFlagSet(c, output)
+	for _, group := range c.groups {
+		group(fs, &f)
+	}
+	if err := fs.setFromEnv(); err != nil {
+		return f, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return f, err
+	}
+	if fs.NArg() > 0 {
+		return f, fmt.Errorf("flags: unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := fs.driverFromDSN(&f); err != nil {
+		return f, err
+	}
+	if err := validateDSN(f.Driver); err != nil {
+		return f, err
+	}
+	if err := registerTLS(f.Driver); err != nil {
+		return f, err
 	}
 
+	if f.ConfigFile != "" {
+		c, err := LoadConfig(f.ConfigFile)
+		if err != nil {
+			return f, err
+		}
+		if err := c.apply(&f); err != nil {
+			return f, err
+		}
+	}
+	if f.Duration > 0 && (len(f.Rows.Counts) > 0 || len(f.Rows.Ratios) > 0) {
+		return f, errors.New("flags: -rows is not supported with -duration, the tables are filled until the duration is over")
+	}
 	f.Parsed = true
+	return f, nil
+}
+
+// connectionFlags are the flags of the database connection
+func connectionFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Driver.DSN, "dsn", "", "", "Native DSN or connection URL (postgres://, mysql://), the driver is taken from the URL scheme")
+	fs.StringVar(&f.Driver.Driver, "driver", "D", "mysql", "Driver for the database connection (mysql, postgres)")
+	fs.StringVar(&f.Driver.Username, "user", "u", "test", "Username for the database connection")
+	fs.StringVar(&f.Driver.Password, "password", "p", "test", "Password for the database connection")
+	fs.StringVar(&f.Driver.Database, "database", "d", "test", "Database of the database connection")
+	fs.StringVar(&f.Driver.Host, "host", "", "localhost", "Host for the database connection")
+	fs.StringVar(&f.Driver.Port, "port", "P", "3306", "Port for the database connection")
+	fs.StringVar(&f.Driver.Socket, "socket", "", "", "Unix domain socket used instead of the host and port (the socket directory on Postgres)")
+	fs.StringVar(&f.Driver.TLSMode, "tls-mode", "", "", "TLS of the connection: disable, require (no verification), verify-ca or verify-full")
+	fs.StringVar(&f.Driver.TLSCA, "tls-ca", "", "", "CA bundle (PEM) verifying the server certificate")
+	fs.StringVar(&f.Driver.TLSCert, "tls-cert", "", "", "Client certificate (PEM)")
+	fs.StringVar(&f.Driver.TLSKey, "tls-key", "", "", "Key of the client certificate (PEM)")
+	fs.IntVar(&f.MaxIdleConns, "max-idle-conns", "i", 200, "Number of max sql db idle connections")
+	fs.IntVar(&f.MaxOpenConns, "max-open-conns", "o", 1000, "Number of max sql db open connections")
+	fs.DurationVar(&f.ConnMaxLifetimeInSec, "conn-max-lifetime", "l", 100*time.Second, "Maximum lifetime of each open connection")
+}
+
+// tableFlags are the flags selecting the tables
+func tableFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Table, "table", "t", "", "Table for fuzzing, the tables are discovered if it is empty")
+	fs.Var(&f.Include, "include", "", "Fuzz only the tables matching the pattern (glob or re:regexp, repeatable)")
+	fs.Var(&f.Exclude, "exclude", "", "Skip the tables matching the pattern (glob or re:regexp, repeatable)")
+}
+
+// rowFlags are the flags of the generated rows
+func rowFlags(fs *flagSet, f *Flags) {
+	fs.IntVar(&f.Num, "num", "n", 1000, "Number of rows")
+	fs.Var(&f.Rows, "rows", "", "Per-table number of rows or ratios (users=1000,orders:users=20)")
+	fs.IntVar(&f.Seed, "seed", "s", 0, "Seed value for reproducibility, 0 seeds from the current time")
+}
+
+// configFlags are the flags of the commands mapping the columns to fields
+func configFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.ConfigFile, "config", "", "", "JSON config file of the rows and the type mapping, flags take precedence over it")
+}
+
+// fillFlags are the flags of the inserts
+func fillFlags(fs *flagSet, f *Flags) {
+	fs.IntVar(&f.Workers, "workers", "w", 20, "Number of workers")
+	fs.IntVar(&f.TargetRows, "target-rows", "", 0, "Insert only the rows missing to reach this number of rows in each table")
+	fs.Var(&f.TargetSize, "target-size", "", "Insert -num rows batches until the table reaches this size on disk (e.g. 512MB, 10GB)")
+	fs.DurationVar(&f.Duration, "duration", "", 0, "Insert rows into all the tables concurrently for this long instead of a fixed number of rows (e.g. 30m), the first row of each table is inserted in insertion order and -rows is rejected")
+	fs.Float64Var(&f.Rate, "rate", "", 0, "Maximum number of rows inserted per second by all the workers (0 means unlimited)")
+	fs.DurationVar(&f.QueryTimeout, "query-timeout", "", 0, "Timeout of each insert (0 means no timeout)")
+	fs.IntVar(&f.Retries, "retries", "", 3, "Number of retries of the inserts failed with deadlocks, lock timeouts or connections failed before the insert was sent")
+	fs.DurationVar(&f.RetryBackoff, "retry-backoff", "", 50*time.Millisecond, "Delay before the first retry, doubled on every retry")
+	fs.DurationVar(&f.RetryMaxBackoff, "retry-max-backoff", "", 5*time.Second, "Maximum delay between the retries")
+	fs.Float64Var(&f.MaxErrorRate, "max-error-rate", "", 0.5, "The run fails if the ratio of failed inserts of a table is above this (0-1)")
+	fs.DurationVar(&f.Progress, "progress", "", 10*time.Second, "Interval of the progress reports (0 disables them)")
+	fs.StringVar(&f.MetricsAddr, "metrics-addr", "", "", "Address of the Prometheus metrics endpoint, e.g. :9100 (disabled by default)")
+	fs.DurationVar(&f.MetricsLinger, "metrics-linger", "", 0, "Keep serving the final metrics for this long after the run, or until a signal, so the last scrape sees them")
+	fs.StringVar(&f.Report, "report", "", "", "Write a json report of the run (schema, insertion order, outcome) into this file")
+	fs.StringVar(&f.Journal, "journal", "", "", "Append the primary keys of the inserted rows to this file, the clean command deletes them")
+	fs.BoolVar(&f.Truncate, "truncate", "", false, "Delete every row of the selected tables before fuzzing, referencing tables first")
+	fs.BoolVar(&f.TruncateCascade, "truncate-cascade", "", false, "Truncate ignoring the foreign keys (TRUNCATE ... CASCADE on Postgres, FOREIGN_KEY_CHECKS=0 on MySQL)")
+	fs.StringVar(&f.SummaryFormat, "summary", "", "text", "Format of the summary printed at the end of the run (text, json)")
+}
+
+// dumpFlags are the flags of the generated file
+func dumpFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Output, "output", "", "-", "File of the generated rows, - is the standard output")
+	fs.StringVar(&f.DumpFormat, "format", "", "sql", "Format of the generated rows (sql, json)")
+}
+
+// describeFlags are the flags of the describe command
+func describeFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Snapshot, "snapshot", "", "", "Write the described schema into this json snapshot file")
+}
+
+// snapshotFlags are the flags generating from a schema snapshot
+func snapshotFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Snapshot, "snapshot", "", "", "Read the schema from this snapshot file of the describe command instead of the database")
+}
+
+// cleanFlags are the flags of the clean command
+func cleanFlags(fs *flagSet, f *Flags) {
+	fs.StringVar(&f.Journal, "journal", "", "", "Journal file written by the fill command")
+}
+
+// driverFromDSN sets the driver based on the scheme of the DSN URL, the
+// driver set explicitly should match the scheme
+func (fs *flagSet) driverFromDSN(f *Flags) error {
+	i := strings.Index(f.Driver.DSN, "://")
+	if i < 0 {
+		return nil
+	}
+	var driver string
+	switch scheme := f.Driver.DSN[:i]; scheme {
+	case "postgres", "postgresql":
+		driver = "postgres"
+	case "mysql":
+		driver = "mysql"
+	default:
+		return fmt.Errorf("flags: unknown dsn scheme: %s", scheme)
+	}
+	explicit := false
+	fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "driver" || fs.aliases[fl.Name] == "driver" {
+			explicit = true
+		}
+	})
+	if explicit && f.Driver.Driver != driver {
+		return fmt.Errorf("flags: driver %s doesn't match the dsn scheme %s", f.Driver.Driver, f.Driver.DSN[:i])
+	}
+	f.Driver.Driver = driver
+	return nil
+}
+
+// validateDSN parses the DSN by the driver to reject the invalid ones
+// before connecting
+func validateDSN(d types.Flags) error {
+	if d.DSN == "" {
+		return nil
+	}
+	var err error
+	switch {
+	case d.Driver == "mysql" && strings.HasPrefix(d.DSN, "mysql://"):
+		_, err = mysql.DSNFromURL(d.DSN)
+	case d.Driver == "mysql":
+		_, err = mysqldriver.ParseDSN(d.DSN)
+	case d.Driver == "postgres" && strings.Contains(d.DSN, "://"):
+		_, err = pq.ParseURL(d.DSN)
+	}
+	if err != nil {
+		return fmt.Errorf("flags: invalid dsn: %w", err)
+	}
+	return nil
+}
+
+// registerTLS builds the TLS config of the flags to reject the invalid ones
+// before connecting, the config of MySQL is registered for its DSN
+func registerTLS(d types.Flags) error {
+	config, err := utils.TLSConfig(d)
+	if err != nil || config == nil {
+		return err
+	}
+	if d.Driver == "mysql" {
+		return mysql.RegisterTLSConfig(config)
+	}
+	return nil
+}
+
+// lookupCommand returns the command by its name
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usage writes the list of the commands
+func usage(output io.Writer) {
+	fmt.Fprintf(output, "Usage: sqlfuzz [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(output, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(output, "\nRun 'sqlfuzz help <command>' for the flags of the command.\n")
+}
+
+// flagSet is a flag set where the flags can have a short alias, the
+// aliases are listed next to the flags in the help
+type flagSet struct {
+	*flag.FlagSet
+	aliases map[string]string
+}
+
+// newFlagSet creates the flag set of the command
+func newFlagSet(c command, output io.Writer) *flagSet {
+	fs := &flagSet{
+		FlagSet: flag.NewFlagSet("sqlfuzz "+c.name, flag.ContinueOnError),
+		aliases: make(map[string]string),
+	}
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: sqlfuzz %s [flags]\n\n%s\n\nFlags:\n", c.name, c.description)
+		fs.VisitAll(func(fl *flag.Flag) {
+			if _, ok := fs.aliases[fl.Name]; ok {
+				return
+			}
+			name := "-" + fl.Name
+			for alias, target := range fs.aliases {
+				if target == fl.Name {
+					name += ", -" + alias
+				}
+			}
+			fmt.Fprintf(output, "  %s\n    \t%s", name, fl.Usage)
+			if fl.DefValue != "" && fl.DefValue != "0" && fl.DefValue != "false" && fl.DefValue != "0s" {
+				fmt.Fprintf(output, " (default %s)", fl.DefValue)
+			}
+			fmt.Fprintln(output)
+		})
+	}
+	return fs
+}
+
+// setFromEnv sets the flags from the SQLFUZZ_ environment variables, the
+// name of the variable is the upper case flag name with underscores
+func (fs *flagSet) setFromEnv() error {
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		if _, ok := fs.aliases[fl.Name]; ok || err != nil {
+			return
+		}
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(fl.Name, "-", "_"))
+		if value, ok := lookupEnv(name); ok {
+			if setErr := fs.Set(fl.Name, value); setErr != nil {
+				err = fmt.Errorf("flags: invalid value of %s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// alias registers the short alias of the flag
+func (fs *flagSet) alias(name, short string) {
+	if short == "" {
+		return
+	}
+	fs.FlagSet.Var(fs.Lookup(name).Value, short, "Alias of -"+name)
+	fs.aliases[short] = name
+}
+
+func (fs *flagSet) StringVar(p *string, name, short, value, usage string) {
+	fs.FlagSet.StringVar(p, name, value, usage)
+	fs.alias(name, short)
+}
+
+func (fs *flagSet) IntVar(p *int, name, short string, value int, usage string) {
+	fs.FlagSet.IntVar(p, name, value, usage)
+	fs.alias(name, short)
+}
+
+func (fs *flagSet) Float64Var(p *float64, name, short string, value float64, usage string) {
+	fs.FlagSet.Float64Var(p, name, value, usage)
+	fs.alias(name, short)
+}
+
+func (fs *flagSet) BoolVar(p *bool, name, short string, value bool, usage string) {
+	fs.FlagSet.BoolVar(p, name, value, usage)
+	fs.alias(name, short)
+}
+
+func (fs *flagSet) DurationVar(p *time.Duration, name, short string, value time.Duration, usage string) {
+	fs.FlagSet.DurationVar(p, name, value, usage)
+	fs.alias(name, short)
+}
+
+func (fs *flagSet) Var(value flag.Value, name, short, usage string) {
+	fs.FlagSet.Var(value, name, usage)
+	fs.alias(name, short)
+}
+
+// Patterns is a repeatable flag collecting table patterns
+type Patterns []string
+
+// String returns the patterns in a comma separated form
+func (p *Patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+// Set appends a new pattern
+func (p *Patterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
 }
@@ -0,0 +1,120 @@
+package flags
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ratio sets the row count of a table relative to another table
+type Ratio struct {
+	Of     string
+	Factor float64
+}
+
+// Rows holds the per-table row counts and ratios. It can be set from the
+// CLI as a comma separated list of table=count (users=1000) and
+// table:other=factor (orders:users=20) entries.
+type Rows struct {
+	Counts map[string]int
+	Ratios map[string]Ratio
+}
+
+// String returns the rows in the same form as they can be set
+func (r *Rows) String() string {
+	var entries []string
+	for table, count := range r.Counts {
+		entries = append(entries, fmt.Sprintf("%s=%d", table, count))
+	}
+	for table, ratio := range r.Ratios {
+		entries = append(entries, fmt.Sprintf("%s:%s=%v", table, ratio.Of, ratio.Factor))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// Set parses a comma separated list of entries
+func (r *Rows) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("flags: invalid rows entry %q, expected table=count or table:other=factor", entry)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return fmt.Errorf("flags: invalid rows entry %q: %w", entry, err)
+		}
+		if err := r.Add(strings.TrimSpace(parts[0]), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add adds a single entry, the key is either a table name or a
+// table:other pair for ratios
+func (r *Rows) Add(key string, value float64) error {
+	if value < 0 {
+		return fmt.Errorf("flags: negative rows value for %s", key)
+	}
+	if parts := strings.SplitN(key, ":", 2); len(parts) == 2 {
+		if parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("flags: invalid rows ratio %q", key)
+		}
+		if r.Ratios == nil {
+			r.Ratios = make(map[string]Ratio)
+		}
+		delete(r.Counts, parts[0])
+		r.Ratios[parts[0]] = Ratio{Of: parts[1], Factor: value}
+		return nil
+	}
+	if value != float64(int(value)) {
+		return fmt.Errorf("flags: rows count of %s should be an integer", key)
+	}
+	if r.Counts == nil {
+		r.Counts = make(map[string]int)
+	}
+	delete(r.Ratios, key)
+	r.Counts[key] = int(value)
+	return nil
+}
+
+// Merge adds the entries of the other rows, overriding the existing ones
+func (r *Rows) Merge(other Rows) {
+	for table, count := range other.Counts {
+		_ = r.Add(table, float64(count))
+	}
+	for table, ratio := range other.Ratios {
+		_ = r.Add(table+":"+ratio.Of, ratio.Factor)
+	}
+}
+
+// Resolve returns the number of rows should be inserted into the table,
+// def is used for the tables without count
+func (r Rows) Resolve(table string, def int) (int, error) {
+	return r.resolve(table, def, make(map[string]struct{}))
+}
+
+func (r Rows) resolve(table string, def int, visited map[string]struct{}) (int, error) {
+	if count, ok := r.Counts[table]; ok {
+		return count, nil
+	}
+	ratio, ok := r.Ratios[table]
+	if !ok {
+		return def, nil
+	}
+	if _, ok := visited[table]; ok {
+		return 0, fmt.Errorf("flags: circular rows ratio for %s", table)
+	}
+	visited[table] = struct{}{}
+	base, err := r.resolve(ratio.Of, def, visited)
+	if err != nil {
+		return 0, err
+	}
+	return int(float64(base) * ratio.Factor), nil
+}
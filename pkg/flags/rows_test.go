@@ -0,0 +1,51 @@
+package flags
+
+import (
+	"testing"
+)
+
+func TestRowsResolve(t *testing.T) {
+	var rows Rows
+	if err := rows.Set("users=1000, orders:users=20,order_items:orders=2.5"); err != nil {
+		t.Fatal(err)
+	}
+	var scenarios = []struct {
+		table  string
+		output int
+	}{
+		{"users", 1000},
+		{"orders", 20000},
+		{"order_items", 50000},
+		{"products", 10},
+	}
+
+	for _, scenario := range scenarios {
+		output, err := rows.Resolve(scenario.table, 10)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if output != scenario.output {
+			t.Errorf("Invalid rows for %s, out: %d expected: %d", scenario.table, output, scenario.output)
+		}
+	}
+}
+
+func TestRowsResolveCircular(t *testing.T) {
+	var rows Rows
+	if err := rows.Set("a:b=2,b:a=2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rows.Resolve("a", 10); err == nil {
+		t.Error("circular ratios should return an error")
+	}
+}
+
+func TestRowsSetInvalid(t *testing.T) {
+	for _, value := range []string{"users", "users=abc", "users=1.5", ":users=2", "users=-1"} {
+		var rows Rows
+		if err := rows.Set(value); err == nil {
+			t.Errorf("%q should be invalid", value)
+		}
+	}
+}
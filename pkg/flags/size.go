@@ -0,0 +1,50 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ByteSize is a size in bytes set from a human readable form like 512MB or
+// 10GB, units are powers of 1024
+type ByteSize int64
+
+// String returns the size in bytes
+func (b *ByteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+// Set parses the human readable size
+func (b *ByteSize) Set(value string) error {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(v, unit.suffix) {
+			multiplier = unit.multiplier
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("flags: invalid size %q", value)
+	}
+	*b = ByteSize(n * float64(multiplier))
+	return nil
+}
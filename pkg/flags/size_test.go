@@ -0,0 +1,38 @@
+package flags
+
+import (
+	"testing"
+)
+
+func TestByteSizeSet(t *testing.T) {
+	var scenarios = []struct {
+		input  string
+		output ByteSize
+	}{
+		{"1024", 1024},
+		{"10B", 10},
+		{"2k", 2048},
+		{"1.5KB", 1536},
+		{"512MB", 512 << 20},
+		{"10GB", 10 << 30},
+		{"1 T", 1 << 40},
+	}
+
+	for _, scenario := range scenarios {
+		var b ByteSize
+		if err := b.Set(scenario.input); err != nil {
+			t.Error(err)
+			continue
+		}
+		if b != scenario.output {
+			t.Errorf("Invalid size for %s, out: %d expected: %d", scenario.input, b, scenario.output)
+		}
+	}
+
+	for _, input := range []string{"", "GB", "ten", "-1MB"} {
+		var b ByteSize
+		if err := b.Set(input); err == nil {
+			t.Errorf("%q should be invalid", input)
+		}
+	}
+}
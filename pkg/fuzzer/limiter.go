@@ -0,0 +1,48 @@
+package fuzzer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter spaces the inserts evenly to keep a steady rate, it is shared by
+// the workers of the pool
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newLimiter creates a limiter for the given rows per second, nil means
+// no limit
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait blocks until the next n rows can be inserted or the context is done
+func (l *limiter) Wait(ctx context.Context, n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n) * l.interval)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,48 @@
+package fuzzer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	l := newLimiter(200)
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				l.Wait(context.Background(), 1)
+			}
+		}()
+	}
+	wg.Wait()
+	// 100 rows at 200 rows/s, the first one is not delayed
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Errorf("Limiter is too fast, 100 rows took %v", elapsed)
+	}
+}
+
+func TestLimiterNil(t *testing.T) {
+	l := newLimiter(0)
+	if l != nil {
+		t.Fatal("Limiter without rate should be nil")
+	}
+	l.Wait(context.Background(), 100)
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := newLimiter(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	l.Wait(ctx, 1)
+	l.Wait(ctx, 1)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Limiter should not wait after cancellation, took %v", elapsed)
+	}
+}
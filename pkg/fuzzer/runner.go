@@ -1,67 +1,177 @@
 package fuzzer
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/PumpkinSeed/sqlfuzz/drivers"
 	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/action"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/connector"
 	"github.com/PumpkinSeed/sqlfuzz/pkg/flags"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/journal"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
 	_ "github.com/lib/pq"
 )
 
-func getDriverAndDB(f flags.Flags) (types.Driver, *sql.DB) {
-	driver := drivers.New(f.Driver)
+func getDriverAndDB(f flags.Flags) (types.Driver, *sql.DB, error) {
+	driver, err := drivers.New(f.Driver)
+	if err != nil {
+		return nil, nil, err
+	}
 	db := connector.Connection(driver, f)
-	return driver, db
+	return driver, db, nil
+}
+
+// openJournal opens the journal of the inserted rows if it is set by the
+// flags, the journal is nil otherwise
+func openJournal(f flags.Flags) (*journal.Journal, error) {
+	if f.Journal == "" {
+		return nil, nil
+	}
+	return journal.Open(f.Journal)
+}
+
+// closeJournal closes the journal and logs the error
+func closeJournal(j *journal.Journal) {
+	if err := j.Close(); err != nil {
+		log.Print(err)
+	}
 }
 
-func runHelper(f flags.Flags, input action.SQLInsertInput) error {
-	numJobs := f.Num
-	workers := f.Workers
-	jobs := make(chan struct{}, numJobs)
+// Pool is the worker pool of the inserts
+type Pool struct {
+	// Workers is the number of concurrent workers, at least one is started
+	Workers int
+	// Rate is the maximum number of rows inserted per second, 0 means no
+	// limit
+	Rate float64
+	// Deadline produces jobs until it is reached instead of a fixed number
+	// of jobs, the zero time means no deadline
+	Deadline time.Time
+	// OnError is called with the failed inserts, they are logged if it is
+	// nil
+	OnError func(err error)
+	// limiter is shared by the pools of a run, it is created from Rate if
+	// it is nil
+	limiter *limiter
+}
+
+// Insert runs numJobs inserts of the input in the worker pool, it returns
+// the error of the context if the run was interrupted
+func (p Pool) Insert(ctx context.Context, numJobs int, input action.SQLInsertInput) error {
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int, workers)
+	rateLimiter := p.limiter
+	if rateLimiter == nil {
+		rateLimiter = newLimiter(p.Rate)
+	}
 	wg := &sync.WaitGroup{}
 	wg.Add(workers)
 	for w := 0; w < workers; w++ {
-		go worker(jobs, wg, f, input)
+		go p.worker(ctx, jobs, wg, input, rateLimiter)
 	}
 
-	for j := 0; j < numJobs; j++ {
-		jobs <- struct{}{}
+	more := func(j int) bool { return j < numJobs }
+	if !p.Deadline.IsZero() {
+		more = func(int) bool { return !p.expired() }
+	}
+produce:
+	for j := 0; more(j); j++ {
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+			break produce
+		}
 	}
 	close(jobs)
 	wg.Wait()
 
-	return nil
+	return ctx.Err()
 }
 
-func worker(jobs <-chan struct{}, wg *sync.WaitGroup, f flags.Flags, input action.SQLInsertInput) {
+func (p Pool) worker(ctx context.Context, jobs <-chan int, wg *sync.WaitGroup, input action.SQLInsertInput, rateLimiter *limiter) {
 	defer wg.Done()
-	driver := drivers.New(f.Driver)
-	db := connector.Connection(driver, f)
-	defer func() {
-		if err := db.Close(); err != nil {
-			log.Print(err)
+	for job := range jobs {
+		rateLimiter.Wait(ctx, input.Rows(job))
+		// The jobs queued before the deadline are dropped after it
+		if ctx.Err() != nil || p.expired() {
+			continue
 		}
-	}()
-	for range jobs {
-		if err := input.Insert(); err != nil {
-			log.Println(err)
+		if err := input.Insert(ctx, job); err != nil && ctx.Err() == nil {
+			if p.OnError != nil {
+				p.OnError(err)
+			} else {
+				log.Println(err)
+			}
 		}
 	}
 }
 
-// Run the commands in a worker pool
-func Run(fields []types.FieldDescriptor, f flags.Flags) error {
-	driver, db := getDriverAndDB(f)
+// expired reports whether the deadline of the pool is over
+func (p Pool) expired() bool {
+	return !p.Deadline.IsZero() && !time.Now().Before(p.Deadline)
+}
+
+func runHelper(ctx context.Context, f flags.Flags, numJobs int, input action.SQLInsertInput) error {
+	pool := Pool{Workers: f.Workers, Rate: f.Rate}
+	if f.Duration > 0 {
+		pool.Deadline = time.Now().Add(f.Duration)
+	}
+	return pool.Insert(ctx, numJobs, input)
+}
+
+// tableRand returns the random generator of the table, the seed of the
+// flags is mixed with the table name to generate different rows into the
+// tables of the same run
+func tableRand(f flags.Flags, table string) *rand.Rand {
+	if f.Seed == 0 {
+		return generator.NewRand(0)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(table))
+	return generator.NewRand(int64(f.Seed) ^ int64(h.Sum64()))
+}
+
+// retryPolicy returns the retry policy of the inserts set by the flags
+func retryPolicy(f flags.Flags) action.RetryPolicy {
+	return action.RetryPolicy{
+		Attempts:   f.Retries,
+		Backoff:    f.RetryBackoff,
+		MaxBackoff: f.RetryMaxBackoff,
+	}
+}
+
+// Run the commands in a worker pool, the outcome of the inserts is
+// recorded into the optional collector
+func Run(ctx context.Context, fields []types.FieldDescriptor, f flags.Flags, collector *stats.Collector) error {
+	driver, db, err := getDriverAndDB(f)
+	if err != nil {
+		return err
+	}
+	collector.TrackDB(db)
+	defer collector.UntrackDB(db)
 	defer func() {
 		if err := db.Close(); err != nil {
 			log.Print(err)
 		}
 	}()
+	j, err := openJournal(f)
+	if err != nil {
+		return err
+	}
+	defer closeJournal(j)
 	sqlInsertInput := action.SQLInsertInput{
 		SingleInsertParams: &action.SingleInsertParams{
 			DB:     db,
@@ -69,22 +179,270 @@ func Run(fields []types.FieldDescriptor, f flags.Flags) error {
 			Table:  f.Table,
 			Fields: fields,
 		},
+		Rand:         tableRand(f, f.Table),
+		Stats:        collector,
+		Journal:      j,
+		QueryTimeout: f.QueryTimeout,
+		Retry:        retryPolicy(f),
+	}
+	if f.TargetSize > 0 {
+		return runToSize(ctx, f, driver, db, sqlInsertInput)
+	}
+	numJobs, err := missingRows(ctx, f.Table, rowsOrTarget(f), f, driver, db)
+	if err != nil {
+		return err
+	}
+	setTotal(collector, f, f.Table, numJobs)
+	if err := runHelper(ctx, f, numJobs, sqlInsertInput); err != nil {
+		return err
+	}
+	return collector.CheckErrorRate(f.Table, f.MaxErrorRate)
+}
+
+// setTotal sets the number of rows planned to insert into the table, it is
+// unknown in duration mode
+func setTotal(collector *stats.Collector, f flags.Flags, table string, rows int) {
+	if f.Duration <= 0 {
+		collector.SetTotal(table, int64(rows))
+	}
+}
+
+// rowsOrTarget returns the target rows in fill-to-target mode, otherwise
+// the number of rows
+func rowsOrTarget(f flags.Flags) int {
+	if f.TargetRows > 0 {
+		return f.TargetRows
+	}
+	return f.Num
+}
+
+// missingRows returns the number of rows should be inserted into the table.
+// In fill-to-target mode num is the target and the existing rows are
+// subtracted from it.
+func missingRows(ctx context.Context, table string, num int, f flags.Flags, driver types.Driver, db *sql.DB) (int, error) {
+	if f.TargetRows <= 0 {
+		return num, nil
+	}
+	count, err := driver.CountRows(ctx, table, db)
+	if err != nil {
+		return 0, err
+	}
+	if count >= num {
+		log.Printf("%s table already has %d rows, target is %d\n", table, count, num)
+		return 0, nil
+	}
+	return num - count, nil
+}
+
+// maxStalledBatches is the number of batches the size of the table may not
+// grow in, the size statistics of the databases are updated lazily
+const maxStalledBatches = 3
+
+// runToSize inserts batches of f.Num rows until the table reaches the
+// target size on disk. It fails if none of the rows of a batch are inserted
+// or the size doesn't grow for maxStalledBatches batches.
+func runToSize(ctx context.Context, f flags.Flags, driver types.Driver, db *sql.DB, input action.SQLInsertInput) error {
+	if f.Num <= 0 {
+		return errors.New("fuzzer: number of rows should be positive in target size mode")
+	}
+	var mu sync.Mutex
+	var failed int
+	pool := Pool{
+		Workers: f.Workers,
+		Rate:    f.Rate,
+		OnError: func(err error) {
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			log.Println(err)
+		},
+	}
+	lastSize, stalled := int64(-1), 0
+	for {
+		size, err := driver.TableSize(ctx, f.Table, db)
+		if err != nil {
+			return err
+		}
+		if size >= int64(f.TargetSize) {
+			log.Printf("%s table reached %d bytes, target is %d\n", f.Table, size, f.TargetSize)
+			return nil
+		}
+		if size > lastSize {
+			lastSize, stalled = size, 0
+		} else {
+			stalled++
+		}
+		if stalled >= maxStalledBatches {
+			return fmt.Errorf("fuzzer: size of %s table is not growing, it is %d bytes after %d batches, target is %d",
+				f.Table, size, stalled, f.TargetSize)
+		}
+		setTotal(input.Stats, f, f.Table, f.Num)
+		failed = 0
+		if err := pool.Insert(ctx, f.Num, input); err != nil {
+			return err
+		}
+		if err := input.Stats.CheckErrorRate(f.Table, f.MaxErrorRate); err != nil {
+			return err
+		}
+		if failed == f.Num {
+			return fmt.Errorf("fuzzer: none of the %d rows of the batch were inserted into %s table", f.Num, f.Table)
+		}
 	}
-	return runHelper(f, sqlInsertInput)
 }
 
-func RunMulti(tableToFieldsMap map[string][]types.FieldDescriptor, insertionOrder []string, f flags.Flags) error {
-	driver, db := getDriverAndDB(f)
+// RunMulti fills the tables in insertion order, the number of rows of each
+// table is resolved from the per-table rows of the flags
+func RunMulti(ctx context.Context, tableToFieldsMap map[string][]types.FieldDescriptor, insertionOrder []string,
+	f flags.Flags, collector *stats.Collector) error {
+	if f.TargetSize > 0 {
+		return errors.New("fuzzer: target size is not supported by multi-table inserts")
+	}
+	driver, db, err := getDriverAndDB(f)
+	if err != nil {
+		return err
+	}
+	collector.TrackDB(db)
+	defer collector.UntrackDB(db)
 	defer func() {
 		if err := db.Close(); err != nil {
 			log.Print(err)
 		}
 	}()
+	num := rowsOrTarget(f)
+	tableToRowCount := make(map[string]int, len(insertionOrder))
+	numJobs := 0
+	for _, table := range insertionOrder {
+		count, err := f.Rows.Resolve(table, num)
+		if err != nil {
+			return err
+		}
+		if count, err = missingRows(ctx, table, count, f, driver, db); err != nil {
+			return err
+		}
+		tableToRowCount[table] = count
+		setTotal(collector, f, table, count)
+		if count > numJobs {
+			numJobs = count
+		}
+	}
+	j, err := openJournal(f)
+	if err != nil {
+		return err
+	}
+	defer closeJournal(j)
 	sqlInsertInput := action.SQLInsertInput{MultiInsertParams: &action.MultiInsertParams{
 		DB:               db,
 		Driver:           driver,
 		InsertionOrder:   insertionOrder,
 		TableToFieldsMap: tableToFieldsMap,
-	}}
-	return runHelper(f, sqlInsertInput)
+		TableToRowCount:  tableToRowCount,
+		NumJobs:          numJobs,
+	}, Rand: generator.NewRand(int64(f.Seed)), Stats: collector, Journal: j, QueryTimeout: f.QueryTimeout, Retry: retryPolicy(f)}
+	if err := runHelper(ctx, f, numJobs, sqlInsertInput); err != nil {
+		return err
+	}
+	for _, table := range insertionOrder {
+		if err := collector.CheckErrorRate(table, f.MaxErrorRate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFor fills the tables concurrently until the duration of the flags is
+// over, the deadline and the rate limit are shared by the tables and the
+// workers are split between them. The tables should be in insertion order,
+// their first rows are inserted one by one in that order so the referencing
+// tables find a row of the referenced ones. The first failed table stops
+// the run.
+func RunFor(ctx context.Context, tableToFieldsMap map[string][]types.FieldDescriptor, tables []string,
+	f flags.Flags, collector *stats.Collector) error {
+	if f.Duration <= 0 {
+		return errors.New("fuzzer: duration should be positive")
+	}
+	if f.TargetRows > 0 || f.TargetSize > 0 {
+		return errors.New("fuzzer: duration is not supported in fill-to-target mode")
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	driver, db, err := getDriverAndDB(f)
+	if err != nil {
+		return err
+	}
+	collector.TrackDB(db)
+	defer collector.UntrackDB(db)
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	j, err := openJournal(f)
+	if err != nil {
+		return err
+	}
+	defer closeJournal(j)
+
+	inputs := make([]action.SQLInsertInput, 0, len(tables))
+	for _, table := range tables {
+		inputs = append(inputs, action.SQLInsertInput{
+			SingleInsertParams: &action.SingleInsertParams{
+				DB:     db,
+				Driver: driver,
+				Table:  table,
+				Fields: tableToFieldsMap[table],
+			},
+			Rand:         tableRand(f, table),
+			Stats:        collector,
+			Journal:      j,
+			QueryTimeout: f.QueryTimeout,
+			Retry:        retryPolicy(f),
+		})
+	}
+	deadline := time.Now().Add(f.Duration)
+	rateLimiter := newLimiter(f.Rate)
+	if err := insertFirstRows(ctx, inputs, rateLimiter); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	workers := f.Workers / len(tables)
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make(chan error, len(tables))
+	for i, input := range inputs {
+		pool := Pool{Workers: workers, Deadline: deadline, limiter: rateLimiter}
+		go func(table string, input action.SQLInsertInput) {
+			err := pool.Insert(ctx, 0, input)
+			if err == nil {
+				err = collector.CheckErrorRate(table, f.MaxErrorRate)
+			}
+			errs <- err
+		}(tables[i], input)
+	}
+	var firstErr error
+	for range tables {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// insertFirstRows inserts a row into every table in the order of the
+// inputs, the failed inserts are logged and counted by the collector
+func insertFirstRows(ctx context.Context, inputs []action.SQLInsertInput, rateLimiter *limiter) error {
+	for _, input := range inputs {
+		rateLimiter.Wait(ctx, input.Rows(0))
+		if err := input.Insert(ctx, 0); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Println(err)
+		}
+	}
+	return ctx.Err()
 }
@@ -0,0 +1,116 @@
+package fuzzer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/action"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/flags"
+)
+
+// baseDriver is embedded by the fake drivers, the alias avoids the clash
+// of the embedded field with the Driver method
+type baseDriver = types.Driver
+
+// sizeDriver is a driver reporting a constant table size
+type sizeDriver struct {
+	baseDriver
+	size int64
+}
+
+func (d sizeDriver) TableSize(context.Context, string, *sql.DB) (int64, error) {
+	return d.size, nil
+}
+
+func (d sizeDriver) ClassifyError(error) types.ErrorCategory {
+	return types.OtherError
+}
+
+func TestRunToSize(t *testing.T) {
+	var scenarios = []struct {
+		before func(ctx context.Context, row *action.Row) error
+		err    string
+	}{
+		{
+			before: func(context.Context, *action.Row) error { return errors.New("insert failed") },
+			err:    "none of the 5 rows",
+		},
+		{
+			before: func(context.Context, *action.Row) error { return action.ErrSkipRow },
+			err:    "is not growing",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		driver := sizeDriver{size: 10}
+		f := flags.Flags{Num: 5, Workers: 2, TargetSize: 100, MaxErrorRate: 1}
+		f.Table = "users"
+		input := action.SQLInsertInput{
+			SingleInsertParams: &action.SingleInsertParams{Driver: driver, Table: f.Table},
+			BeforeInsert:       scenario.before,
+		}
+		err := runToSize(context.Background(), f, driver, nil, input)
+		if err == nil || !strings.Contains(err.Error(), scenario.err) {
+			t.Errorf("Invalid error, out: %v expected: %s", err, scenario.err)
+		}
+	}
+}
+
+func TestPoolDeadline(t *testing.T) {
+	var rows int64
+	input := action.SQLInsertInput{
+		SingleInsertParams: &action.SingleInsertParams{Driver: sizeDriver{}, Table: "users"},
+		BeforeInsert: func(context.Context, *action.Row) error {
+			atomic.AddInt64(&rows, 1)
+			return action.ErrSkipRow
+		},
+	}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	rateLimiter := newLimiter(100)
+	wg := &sync.WaitGroup{}
+	for p := 0; p < 2; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool := Pool{Workers: 2, Deadline: deadline, limiter: rateLimiter}
+			if err := pool.Insert(context.Background(), 0, input); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if time.Now().Before(deadline) {
+		t.Error("Pools stopped before the deadline")
+	}
+	// 20 rows in 200ms shared by the pools
+	if rows < 15 || rows > 22 {
+		t.Errorf("Invalid number of rows inserted by the pools: %d", rows)
+	}
+}
+
+func TestInsertFirstRows(t *testing.T) {
+	var order []string
+	var inputs []action.SQLInsertInput
+	for _, table := range []string{"users", "orders", "order_items"} {
+		inputs = append(inputs, action.SQLInsertInput{
+			SingleInsertParams: &action.SingleInsertParams{Driver: sizeDriver{}, Table: table},
+			BeforeInsert: func(ctx context.Context, row *action.Row) error {
+				order = append(order, row.Table)
+				return action.ErrSkipRow
+			},
+		})
+	}
+	if err := insertFirstRows(context.Background(), inputs, newLimiter(0)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "users,orders,order_items" {
+		t.Errorf("Invalid order of the first rows: %v", order)
+	}
+}
@@ -0,0 +1,216 @@
+// Package generator generates the random values of the columns. The
+// values are generated by the generator registered for the column, or by
+// the generator of the field type the column is mapped to by the driver.
+package generator
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// Generator generates the value of a column. The descriptor is the
+// described column, the field is the type it is mapped to by the driver.
+type Generator interface {
+	Generate(rng *rand.Rand, descriptor types.FieldDescriptor, field types.Field) (interface{}, error)
+}
+
+// Func is a function used as Generator
+type Func func(rng *rand.Rand, descriptor types.FieldDescriptor, field types.Field) (interface{}, error)
+
+// Generate calls the function
+func (fn Func) Generate(rng *rand.Rand, descriptor types.FieldDescriptor, field types.Field) (interface{}, error) {
+	return fn(rng, descriptor, field)
+}
+
+// Mapper maps the described columns to fields, it is implemented by the
+// drivers without connecting to the database
+type Mapper interface {
+	MapField(descriptor types.FieldDescriptor) types.Field
+}
+
+// Registry holds the generators by column and by field type, the types
+// not registered in it are generated by the built-in generators
+type Registry struct {
+	mu       sync.RWMutex
+	byColumn map[string]Generator
+	byType   map[types.FieldType]Generator
+}
+
+// builtins are the generators of the field types, they are read-only
+var builtins = map[types.FieldType]Generator{
+	types.String:       Func(generateString),
+	types.Int16:        Func(generateInt16),
+	types.Int32:        Func(generateInt32),
+	types.Float:        Func(generateFloat),
+	types.Blob:         Func(generateBlob),
+	types.Text:         Func(generateText),
+	types.Enum:         Func(generateEnum),
+	types.Bool:         Func(generateBool),
+	types.Json:         Func(generateJSON),
+	types.Time:         Func(generateTime),
+	types.Year:         Func(generateYear),
+	types.XML:          Func(generateXML),
+	types.UUID:         Func(generateUUID),
+	types.BinaryString: Func(generateBinaryString),
+	types.Unknown:      Func(generateUnknown),
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byColumn: make(map[string]Generator),
+		byType:   make(map[types.FieldType]Generator),
+	}
+}
+
+// RegisterType sets the generator of the field type
+func (r *Registry) RegisterType(fieldType types.FieldType, g Generator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byType[fieldType] = g
+}
+
+// RegisterColumn sets the generator of the column, the column is either
+// table.column or a column name of every table
+func (r *Registry) RegisterColumn(column string, g Generator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byColumn[column] = g
+}
+
+// Lookup returns the generator of the column of the table. The column
+// generators take precedence over the type generators: table.column, then
+// column, then the field type in the registry and then the built-in
+// generator of the field type. The nil registry has only the built-ins.
+func (r *Registry) Lookup(table string, descriptor types.FieldDescriptor, field types.Field) (Generator, bool) {
+	if r != nil {
+		for _, column := range []string{table + "." + descriptor.Field, descriptor.Field} {
+			if g, ok := r.column(column); ok {
+				return g, true
+			}
+		}
+		if g, ok := r.fieldType(field.Type); ok {
+			return g, true
+		}
+	}
+	g, ok := builtins[field.Type]
+	return g, ok
+}
+
+// Generate generates the value of the column of the table, the nil
+// registry generates by the built-in generators
+func (r *Registry) Generate(rng *rand.Rand, mapper Mapper, table string, descriptor types.FieldDescriptor) (interface{}, error) {
+	field := mapper.MapField(descriptor)
+	g, ok := r.Lookup(table, descriptor, field)
+	if !ok {
+		return nil, fmt.Errorf("generator: no generator of %s.%s (%s)", table, descriptor.Field, field.Type)
+	}
+	return g.Generate(rng, descriptor, field)
+}
+
+// Generate generates the value of the column of the table by the built-in
+// generators
+func Generate(rng *rand.Rand, mapper Mapper, table string, descriptor types.FieldDescriptor) (interface{}, error) {
+	var r *Registry
+	return r.Generate(rng, mapper, table, descriptor)
+}
+
+func (r *Registry) column(column string) (Generator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.byColumn[column]
+	return g, ok
+}
+
+func (r *Registry) fieldType(fieldType types.FieldType) (Generator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.byType[fieldType]
+	return g, ok
+}
+
+// generateString generates at most 20 characters, long enough to keep the
+// values of the unique columns distinct
+func generateString(rng *rand.Rand, _ types.FieldDescriptor, field types.Field) (interface{}, error) {
+	if field.Length > 0 && field.Length < 20 {
+		return randomString(rng, field.Length), nil
+	}
+	return randomString(rng, 20), nil
+}
+
+func generateInt16(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return number(rng, 1, 32766), nil
+}
+
+func generateInt32(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return number(rng, 1, 2147483647), nil
+}
+
+func generateFloat(rng *rand.Rand, descriptor types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	max := 2147483647
+	if descriptor.Precision.Valid && descriptor.Scale.Valid {
+		max = int(math.Pow10(descriptor.Precision.Int - descriptor.Scale.Int))
+	}
+	return number(rng, 1, max), nil
+}
+
+func generateBlob(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return base64.StdEncoding.EncodeToString([]byte(randomString(rng, 12))), nil
+}
+
+func generateText(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return randomString(rng, 12), nil
+}
+
+func generateEnum(rng *rand.Rand, _ types.FieldDescriptor, field types.Field) (interface{}, error) {
+	if len(field.Enum) == 0 {
+		return nil, nil
+	}
+	return field.Enum[rng.Intn(len(field.Enum))], nil
+}
+
+func generateBool(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return rng.Intn(2) == 0, nil
+}
+
+func generateJSON(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return fmt.Sprintf(
+		`{"%s": "%s", "%s": "%s"}`,
+		randomChars(rng, letters, 6),
+		randomChars(rng, letters, 6),
+		randomChars(rng, letters, 6),
+		randomChars(rng, letters, 6),
+	), nil
+}
+
+func generateTime(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return randomTime(rng), nil
+}
+
+func generateYear(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return number(rng, 1901, 2155), nil
+}
+
+func generateXML(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return randomXML(rng), nil
+}
+
+func generateUUID(rng *rand.Rand, _ types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	return randomUUID(rng), nil
+}
+
+func generateBinaryString(rng *rand.Rand, _ types.FieldDescriptor, field types.Field) (interface{}, error) {
+	return binaryString(rng, int(field.Length)), nil
+}
+
+// generateUnknown logs the unmapped column and leaves it NULL
+func generateUnknown(_ *rand.Rand, descriptor types.FieldDescriptor, _ types.Field) (interface{}, error) {
+	log.Printf("unknown field type: %s (%s)\n", descriptor.Field, descriptor.Type)
+	return nil, nil
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/postgres"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/volatiletech/null"
+)
+
+func TestGenerateSeed(t *testing.T) {
+	driver := postgres.New(types.Flags{})
+	fields := []types.FieldDescriptor{
+		{Field: "name", Type: "character varying", Length: null.IntFrom(12)},
+		{Field: "age", Type: "integer"},
+		{Field: "active", Type: "boolean"},
+		{Field: "created", Type: "timestamp without time zone"},
+		{Field: "uid", Type: "uuid"},
+		{Field: "doc", Type: "xml"},
+		{Field: "email", Type: "character varying", Length: null.IntFrom(255)},
+	}
+	generate := func(seed int64) []interface{} {
+		rng := NewRand(seed)
+		values := make([]interface{}, 0, len(fields))
+		for _, field := range fields {
+			val, err := Generate(rng, driver, "users", field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, val)
+		}
+		return values
+	}
+
+	first, second := generate(42), generate(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("The same seed should generate the same values, out: %v and %v", first, second)
+	}
+	if reflect.DeepEqual(first, generate(43)) {
+		t.Errorf("Different seeds should generate different values, out: %v", first)
+	}
+	if uid, ok := first[4].(string); !ok || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uid) {
+		t.Errorf("Invalid uuid: %v", first[4])
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	constant := func(value string) Generator {
+		return Func(func(*rand.Rand, types.FieldDescriptor, types.Field) (interface{}, error) {
+			return value, nil
+		})
+	}
+	registry := NewRegistry()
+	registry.RegisterType(types.Int32, constant("int32"))
+	registry.RegisterColumn("sku", constant("sku"))
+	registry.RegisterColumn("orders.sku", constant("orders sku"))
+
+	driver := postgres.New(types.Flags{})
+	var scenarios = []struct {
+		table  string
+		field  types.FieldDescriptor
+		output interface{}
+	}{
+		{table: "orders", field: types.FieldDescriptor{Field: "sku", Type: "text"}, output: "orders sku"},
+		{table: "products", field: types.FieldDescriptor{Field: "sku", Type: "integer"}, output: "sku"},
+		{table: "products", field: types.FieldDescriptor{Field: "id", Type: "integer"}, output: "int32"},
+		{table: "products", field: types.FieldDescriptor{Field: "amount", Type: "money"}, output: nil},
+	}
+
+	for _, scenario := range scenarios {
+		output, err := registry.Generate(NewRand(1), driver, scenario.table, scenario.field)
+		if err != nil {
+			t.Errorf("Unexpected error for %s.%s: %v", scenario.table, scenario.field.Field, err)
+			continue
+		}
+		if output != scenario.output {
+			t.Errorf("Invalid value for %s.%s, out: %v expected: %v", scenario.table, scenario.field.Field, output, scenario.output)
+		}
+	}
+}
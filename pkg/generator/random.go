@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5/data"
+)
+
+const (
+	alphanumeric = "abcdefghijklmnopqrstuvwxyz" +
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// lockedSource is a rand.Source safe for concurrent use, it is shared by
+// the workers of a run
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// NewRand creates a random generator safe for concurrent use. The same
+// seed generates the same values, 0 seeds it from the current time.
+func NewRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// number returns a random number in [min, max]
+func number(rng *rand.Rand, min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + rng.Intn(max-min+1)
+}
+
+// randomString generates a length size random string
+func randomString(rng *rand.Rand, length int16) string {
+	return randomChars(rng, alphanumeric, int(length))
+}
+
+// randomChars generates a length size random string from the charset
+func randomChars(rng *rand.Rand, charset string, length int) string {
+	if length < 0 {
+		length = 0
+	}
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = charset[rng.Intn(len(charset))]
+	}
+	return string(b)
+}
+
+// binaryString generates a length size string of 0 and 1
+func binaryString(rng *rand.Rand, length int) string {
+	return randomChars(rng, "01", length)
+}
+
+// randomTime returns a random time between 1980 and 2028
+func randomTime(rng *rand.Rand) time.Time {
+	return time.Date(
+		number(rng, 1980, 2028),
+		time.Month(number(rng, 0, 12)),
+		number(rng, 1, 31),
+		number(rng, 0, 23),
+		number(rng, 0, 59),
+		number(rng, 0, 59),
+		number(rng, 0, 999999999),
+		time.UTC)
+}
+
+// randomUUID returns a random version 4 UUID
+func randomUUID(rng *rand.Rand) string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(rng.Intn(256))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// randomXML returns an xml document of two records with random names and
+// passwords
+func randomXML(rng *rand.Rand) string {
+	var b strings.Builder
+	b.WriteString("<xml>\n")
+	for i := 0; i < 2; i++ {
+		fmt.Fprintf(&b, "    <record>\n        <first_name>%s</first_name>\n        <last_name>%s</last_name>\n"+
+			"        <password>%s</password>\n    </record>\n",
+			pick(rng, data.Person["first"]), pick(rng, data.Person["last"]), randomChars(rng, letters, 12))
+	}
+	b.WriteString("</xml>")
+	return b.String()
+}
+
+// pick returns a random element of the list
+func pick(rng *rand.Rand, list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[rng.Intn(len(list))]
+}
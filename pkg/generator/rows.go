@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// Row is a generated row, the values by column name
+type Row map[string]interface{}
+
+// Rows generates the rows of a table from its described columns without
+// database, e.g. payloads mirroring the table for load tests. The columns
+// with default value are left out, foreign keys are generated as any other
+// column of their type. It is not safe for concurrent use.
+type Rows struct {
+	mapper   Mapper
+	registry *Registry
+	rng      *rand.Rand
+	table    string
+	fields   []types.FieldDescriptor
+	err      error
+}
+
+// NewRows creates the row generator of the table. The columns are mapped
+// by the mapper, e.g. a driver created by drivers.New without connection.
+// The values are generated by the registry from rng, the nil registry uses
+// the built-in generators and the nil rng is seeded from the current time.
+func NewRows(mapper Mapper, table string, fields []types.FieldDescriptor, rng *rand.Rand, registry *Registry) *Rows {
+	if rng == nil {
+		rng = NewRand(0)
+	}
+	return &Rows{mapper: mapper, registry: registry, rng: rng, table: table, fields: fields}
+}
+
+// Next generates the next row
+func (r *Rows) Next() (Row, error) {
+	row := make(Row, len(r.fields))
+	for _, field := range r.fields {
+		if field.HasDefaultValue {
+			continue
+		}
+		val, err := r.registry.Generate(r.rng, r.mapper, r.table, field)
+		if err != nil {
+			return nil, err
+		}
+		row[field.Field] = val
+	}
+	return row, nil
+}
+
+// Stream generates n rows into the returned channel, or rows until the
+// context is done if n is negative. The channel is closed at the end of
+// the rows, on the cancellation of the context or on the first error of
+// the generation returned by Err.
+func (r *Rows) Stream(ctx context.Context, n int) <-chan Row {
+	rows := make(chan Row)
+	go func() {
+		defer close(rows)
+		for i := 0; n < 0 || i < n; i++ {
+			row, err := r.Next()
+			if err != nil {
+				r.err = err
+				return
+			}
+			select {
+			case rows <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return rows
+}
+
+// Err returns the error stopped the stream, it should be called after the
+// channel of the stream was closed
+func (r *Rows) Err() error {
+	return r.err
+}
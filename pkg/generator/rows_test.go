@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/postgres"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+func TestRows(t *testing.T) {
+	fields := []types.FieldDescriptor{
+		{Field: "id", Type: "integer", HasDefaultValue: true},
+		{Field: "name", Type: "text"},
+		{Field: "age", Type: "smallint"},
+	}
+	rows := NewRows(postgres.New(types.Flags{}), "users", fields, NewRand(1), nil)
+
+	var streamed []Row
+	for row := range rows.Stream(context.Background(), 3) {
+		streamed = append(streamed, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 3 {
+		t.Fatalf("Invalid number of rows: %d", len(streamed))
+	}
+	for _, row := range streamed {
+		if _, ok := row["id"]; ok || len(row) != 2 {
+			t.Errorf("Invalid row, columns with default should be left out: %v", row)
+		}
+	}
+
+	first, err := NewRows(postgres.New(types.Flags{}), "users", fields, NewRand(1), nil).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, streamed[0]) {
+		t.Errorf("The same seed should generate the same rows, out: %v expected: %v", first, streamed[0])
+	}
+}
+
+func TestRowsStreamStop(t *testing.T) {
+	fields := []types.FieldDescriptor{{Field: "name", Type: "text"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := NewRows(postgres.New(types.Flags{}), "users", fields, nil, nil)
+	stream := rows.Stream(ctx, -1)
+	<-stream
+	cancel()
+	for range stream {
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Canceled stream should not fail: %v", err)
+	}
+
+	failure := errors.New("no name")
+	registry := NewRegistry()
+	registry.RegisterColumn("name", Func(func(*rand.Rand, types.FieldDescriptor, types.Field) (interface{}, error) {
+		return nil, failure
+	}))
+	rows = NewRows(postgres.New(types.Flags{}), "users", fields, nil, registry)
+	for range rows.Stream(context.Background(), 10) {
+		t.Error("Failed generation should not send rows")
+	}
+	if err := rows.Err(); !errors.Is(err, failure) {
+		t.Errorf("Invalid error of the stream: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// Entry is the primary key of an inserted row
+type Entry struct {
+	Table string                 `json:"table"`
+	Key   map[string]interface{} `json:"key"`
+}
+
+// Journal appends the primary keys of the inserted rows into a file as
+// json lines, it is safe for concurrent use
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Open opens the journal file for appending, the file is created if it
+// doesn't exist
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Record appends the primary key of the row inserted into the table
+func (j *Journal) Record(table string, key map[string]interface{}) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.encoder.Encode(Entry{Table: table, Key: key}); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// Memory keeps the primary keys of the inserted rows in memory, it is safe
+// for concurrent use
+type Memory struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Record adds the primary key of the row inserted into the table
+func (m *Memory) Record(table string, key map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, Entry{Table: table, Key: key})
+	return nil
+}
+
+// Entries returns the recorded entries in insertion order
+func (m *Memory) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Entry(nil), m.entries...)
+}
+
+// Read reads the entries of the journal file in insertion order
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []Entry
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	decoder.UseNumber()
+	for decoder.More() {
+		var entry Entry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("journal: invalid journal file %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Clean deletes the rows of the entries in reverse foreign key order, the
+// rows of the referencing tables are deleted before the referenced ones. It
+// returns the number of deleted rows.
+func Clean(ctx context.Context, driver types.Driver, db *sql.DB, entries []Entry) (int64, error) {
+	tableToEntries := make(map[string][]Entry)
+	var tables []string
+	for _, entry := range entries {
+		if _, ok := tableToEntries[entry.Table]; !ok {
+			tables = append(tables, entry.Table)
+		}
+		tableToEntries[entry.Table] = append(tableToEntries[entry.Table], entry)
+	}
+	if len(tables) == 0 {
+		return 0, nil
+	}
+	_, insertionOrder, err := driver.MultiDescribe(ctx, tables, db)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for i := len(insertionOrder) - 1; i >= 0; i-- {
+		tableEntries := tableToEntries[insertionOrder[i]]
+		for j := len(tableEntries) - 1; j >= 0; j-- {
+			n, err := deleteRow(ctx, driver, db, tableEntries[j])
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+	}
+	return deleted, nil
+}
+
+// deleteRow deletes the row of the entry
+func deleteRow(ctx context.Context, driver types.Driver, db *sql.DB, entry Entry) (int64, error) {
+	columns := make([]string, 0, len(entry.Key))
+	for column := range entry.Key {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, entry.Key[column])
+	}
+	result, err := db.ExecContext(ctx, driver.Delete(entry.Table, columns), values...)
+	if err != nil {
+		return 0, fmt.Errorf("journal: delete from %s: %w", entry.Table, err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,72 @@
+package journal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fuzz.journal")
+
+	scenarios := []Entry{
+		{Table: "users", Key: map[string]interface{}{"id": int64(9007199254740993)}},
+		{Table: "orders", Key: map[string]interface{}{"user_id": 1, "sku": "abc"}},
+	}
+	for i := range scenarios {
+		// Reopen the journal to check that the entries are appended
+		j, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Record(scenarios[i].Table, scenarios[i].Key); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(scenarios) {
+		t.Fatalf("Invalid number of entries, out: %d expected: %d", len(entries), len(scenarios))
+	}
+	if entries[0].Table != "users" || entries[0].Key["id"] != json.Number("9007199254740993") {
+		t.Errorf("Invalid entry: %+v", entries[0])
+	}
+	if entries[1].Table != "orders" || entries[1].Key["user_id"] != json.Number("1") || entries[1].Key["sku"] != "abc" {
+		t.Errorf("Invalid entry: %+v", entries[1])
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+	if err := j.Record("users", map[string]interface{}{"id": 1}); err != nil {
+		t.Error(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := &Memory{}
+	if err := m.Record("users", map[string]interface{}{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	entries := m.Entries()
+	entries[0].Table = "changed"
+	if output := m.Entries(); len(output) != 1 || output[0].Table != "users" {
+		t.Errorf("Invalid entries: %v", output)
+	}
+}
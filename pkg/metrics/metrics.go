@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
+)
+
+// ContentType is the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the metrics of the collector in the Prometheus text
+// exposition format, every series is labelled with the driver
+func Handler(collector *stats.Collector, driver string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		if err := Write(w, collector, driver); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Write writes the metrics of the collector in the Prometheus text
+// exposition format
+func Write(w io.Writer, collector *stats.Collector, driver string) error {
+	b := bufio.NewWriter(w)
+	driverLabel := label("driver", driver)
+	tables := collector.Summary().Tables
+
+	header(b, "sqlfuzz_rows_inserted_total", "counter", "Number of rows inserted.")
+	for _, t := range tables {
+		sample(b, "sqlfuzz_rows_inserted_total", labels(driverLabel, label("table", t.Name)), float64(t.Inserted))
+	}
+	header(b, "sqlfuzz_insert_errors_total", "counter", "Number of failed inserts by error category.")
+	for _, t := range tables {
+		for category, count := range t.Errors {
+			sample(b, "sqlfuzz_insert_errors_total",
+				labels(driverLabel, label("table", t.Name), label("category", string(category))), float64(count))
+		}
+	}
+	header(b, "sqlfuzz_insert_retries_total", "counter", "Number of retried inserts.")
+	for _, t := range tables {
+		sample(b, "sqlfuzz_insert_retries_total", labels(driverLabel, label("table", t.Name)), float64(t.Retries))
+	}
+	header(b, "sqlfuzz_insert_latency_seconds", "histogram", "Latency of the successful inserts.")
+	for _, t := range tables {
+		histogram(b, "sqlfuzz_insert_latency_seconds", labels(driverLabel, label("table", t.Name)), collector.Histogram(t.Name))
+	}
+
+	dbStats := collector.DBStats()
+	header(b, "sqlfuzz_db_open_connections", "gauge", "Number of established connections.")
+	sample(b, "sqlfuzz_db_open_connections", labels(driverLabel), float64(dbStats.OpenConnections))
+	header(b, "sqlfuzz_db_in_use_connections", "gauge", "Number of connections currently in use.")
+	sample(b, "sqlfuzz_db_in_use_connections", labels(driverLabel), float64(dbStats.InUse))
+	header(b, "sqlfuzz_db_idle_connections", "gauge", "Number of idle connections.")
+	sample(b, "sqlfuzz_db_idle_connections", labels(driverLabel), float64(dbStats.Idle))
+	header(b, "sqlfuzz_db_wait_count_total", "counter", "Number of connections waited for.")
+	sample(b, "sqlfuzz_db_wait_count_total", labels(driverLabel), float64(dbStats.WaitCount))
+	header(b, "sqlfuzz_db_wait_duration_seconds_total", "counter", "Time blocked waiting for a new connection.")
+	sample(b, "sqlfuzz_db_wait_duration_seconds_total", labels(driverLabel), dbStats.WaitDuration.Seconds())
+
+	return b.Flush()
+}
+
+func header(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func histogram(w io.Writer, name, labels string, h stats.Histogram) {
+	var cumulative int64
+	for i, bound := range stats.Buckets {
+		cumulative += h.Counts[i]
+		sample(w, name+"_bucket", labels+","+label("le", strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)), float64(cumulative))
+	}
+	sample(w, name+"_bucket", labels+","+label("le", "+Inf"), float64(h.Count))
+	sample(w, name+"_sum", labels, h.Sum.Seconds())
+	sample(w, name+"_count", labels, float64(h.Count))
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelValueReplacer.Replace(value) + `"`
+}
+
+func labels(l ...string) string {
+	return strings.Join(l, ",")
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
+)
+
+func TestHandler(t *testing.T) {
+	collector := stats.New()
+	collector.Record("users", 2*time.Millisecond, "", nil)
+	collector.Record("users", 3*time.Millisecond, "", nil)
+	collector.Record(`weird"table`, 0, types.ConstraintViolation, errors.New("duplicate key"))
+	collector.RecordRetry("users")
+
+	rec := httptest.NewRecorder()
+	Handler(collector, "mysql").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Invalid content type: %s", ct)
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"# TYPE sqlfuzz_rows_inserted_total counter\n",
+		`sqlfuzz_rows_inserted_total{driver="mysql",table="users"} 2` + "\n",
+		`sqlfuzz_insert_errors_total{driver="mysql",table="weird\"table",category="constraint_violation"} 1` + "\n",
+		`sqlfuzz_insert_retries_total{driver="mysql",table="users"} 1` + "\n",
+		`sqlfuzz_insert_latency_seconds_bucket{driver="mysql",table="users",le="+Inf"} 2` + "\n",
+		`sqlfuzz_insert_latency_seconds_sum{driver="mysql",table="users"} 0.005` + "\n",
+		`sqlfuzz_insert_latency_seconds_count{driver="mysql",table="users"} 2` + "\n",
+		`sqlfuzz_db_open_connections{driver="mysql"} 0` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Missing %q from the metrics:\n%s", expected, body)
+		}
+	}
+}
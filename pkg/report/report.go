@@ -0,0 +1,74 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
+)
+
+// Report describes a run: the schema the data was generated for, the
+// order of the insertions and the outcome per table
+type Report struct {
+	Driver         string                             `json:"driver"`
+	Database       string                             `json:"database"`
+	Seed           int                                `json:"seed"`
+	Start          time.Time                          `json:"start"`
+	End            time.Time                          `json:"end"`
+	Seconds        float64                            `json:"seconds"`
+	InsertionOrder []string                           `json:"insertion_order"`
+	Schema         map[string][]types.FieldDescriptor `json:"schema"`
+	Inserted       int64                              `json:"inserted"`
+	Failed         int64                              `json:"failed"`
+	Tables         []stats.Table                      `json:"tables"`
+	Error          string                             `json:"error,omitempty"`
+}
+
+// New creates a report of a run started now
+func New(driver, database string, seed int) *Report {
+	return &Report{
+		Driver:         driver,
+		Database:       database,
+		Seed:           seed,
+		Start:          time.Now(),
+		InsertionOrder: []string{},
+		Schema:         make(map[string][]types.FieldDescriptor),
+	}
+}
+
+// AddTable records the resolved schema of the table in insertion order
+func (r *Report) AddTable(table string, fields []types.FieldDescriptor) {
+	if r == nil {
+		return
+	}
+	if _, ok := r.Schema[table]; !ok {
+		r.InsertionOrder = append(r.InsertionOrder, table)
+	}
+	r.Schema[table] = fields
+}
+
+// Finish closes the report with the summary and the error of the run
+func (r *Report) Finish(summary stats.Summary, err error) {
+	if r == nil {
+		return
+	}
+	r.End = time.Now()
+	r.Seconds = r.End.Sub(r.Start).Seconds()
+	r.Inserted = summary.Inserted
+	r.Failed = summary.Failed
+	r.Tables = summary.Tables
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Write writes the report as indented json into the file
+func (r *Report) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
+)
+
+func TestReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := New("mysql", "fuzz", 42)
+	r.AddTable("users", []types.FieldDescriptor{{Field: "id", Type: "int", Key: "PRI"}})
+	r.AddTable("orders", []types.FieldDescriptor{{Field: "user_id", Type: "int"}})
+	r.AddTable("users", []types.FieldDescriptor{{Field: "id", Type: "int", Key: "PRI"}})
+	collector := stats.New()
+	collector.Record("users", 0, "", nil)
+	collector.Record("orders", 0, types.ConstraintViolation, errors.New("duplicate key"))
+	r.Finish(collector.Summary(), errors.New("too many errors"))
+
+	path := filepath.Join(dir, "report.json")
+	if err := r.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.InsertionOrder) != 2 || got.InsertionOrder[0] != "users" || got.InsertionOrder[1] != "orders" {
+		t.Errorf("Invalid insertion order: %v", got.InsertionOrder)
+	}
+	if got.Seed != 42 || got.Inserted != 1 || got.Failed != 1 || got.Error != "too many errors" {
+		t.Errorf("Invalid report: %+v", got)
+	}
+	if got.Schema["users"][0].Key != "PRI" {
+		t.Errorf("Invalid schema: %+v", got.Schema)
+	}
+	if got.End.Before(got.Start) {
+		t.Errorf("Invalid timing: %v - %v", got.Start, got.End)
+	}
+}
@@ -0,0 +1,162 @@
+// Package schema reads and writes the snapshots of the described tables,
+// rows can be generated from a snapshot without database
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/volatiletech/null"
+)
+
+// Snapshot is the described schema of the tables: the columns with their
+// foreign keys and the order the tables are filled in. The referenced
+// tables are described as well but they are not part of the insertion
+// order unless they were selected.
+type Snapshot struct {
+	Driver         string
+	InsertionOrder []string
+	Tables         map[string][]types.FieldDescriptor
+}
+
+// snapshotFile is the format of the snapshot file, it is kept apart from
+// the descriptors of the drivers so they can change without breaking the
+// written snapshots
+type snapshotFile struct {
+	Driver         string              `json:"driver"`
+	InsertionOrder []string            `json:"insertion_order"`
+	Tables         map[string][]column `json:"tables"`
+}
+
+// column is a described column of the snapshot file
+type column struct {
+	Field           string      `json:"field"`
+	Type            string      `json:"type"`
+	Null            string      `json:"null,omitempty"`
+	Key             string      `json:"key,omitempty"`
+	Length          *int        `json:"length,omitempty"`
+	Default         *string     `json:"default,omitempty"`
+	Extra           string      `json:"extra,omitempty"`
+	Precision       *int        `json:"precision,omitempty"`
+	Scale           *int        `json:"scale,omitempty"`
+	HasDefaultValue bool        `json:"has_default_value,omitempty"`
+	ForeignKey      *foreignKey `json:"foreign_key,omitempty"`
+}
+
+// foreignKey is the foreign key of a column of the snapshot file
+type foreignKey struct {
+	ConstraintName    string `json:"constraint_name,omitempty"`
+	TableName         string `json:"table_name,omitempty"`
+	ColumnName        string `json:"column_name,omitempty"`
+	ForeignTableName  string `json:"foreign_table_name"`
+	ForeignColumnName string `json:"foreign_column_name"`
+}
+
+// Write writes the snapshot into the file as indented json. The tables are
+// sorted by name and the insertion order is deterministic, so the snapshots
+// of the same schema are equal.
+func Write(path string, snapshot Snapshot) error {
+	file := snapshotFile{
+		Driver:         snapshot.Driver,
+		InsertionOrder: snapshot.InsertionOrder,
+		Tables:         make(map[string][]column, len(snapshot.Tables)),
+	}
+	for table, fields := range snapshot.Tables {
+		columns := make([]column, 0, len(fields))
+		for _, field := range fields {
+			columns = append(columns, toColumn(field))
+		}
+		file.Tables[table] = columns
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Read reads the snapshot file
+func Read(path string) (Snapshot, error) {
+	var snapshot Snapshot
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return snapshot, fmt.Errorf("schema: invalid snapshot file %s: %w", path, err)
+	}
+	if file.Driver == "" {
+		return snapshot, fmt.Errorf("schema: snapshot file %s has no driver", path)
+	}
+	for _, table := range file.InsertionOrder {
+		if _, ok := file.Tables[table]; !ok {
+			return snapshot, fmt.Errorf("schema: table %s of the insertion order is not described in %s", table, path)
+		}
+	}
+	snapshot.Driver = file.Driver
+	snapshot.InsertionOrder = file.InsertionOrder
+	snapshot.Tables = make(map[string][]types.FieldDescriptor, len(file.Tables))
+	for table, columns := range file.Tables {
+		fields := make([]types.FieldDescriptor, 0, len(columns))
+		for _, c := range columns {
+			fields = append(fields, c.fieldDescriptor())
+		}
+		snapshot.Tables[table] = fields
+	}
+	return snapshot, nil
+}
+
+// toColumn converts the field descriptor to the column of the file
+func toColumn(field types.FieldDescriptor) column {
+	c := column{
+		Field:           field.Field,
+		Type:            field.Type,
+		Null:            field.Null,
+		Key:             field.Key,
+		Length:          field.Length.Ptr(),
+		Default:         field.Default.Ptr(),
+		Extra:           field.Extra,
+		Precision:       field.Precision.Ptr(),
+		Scale:           field.Scale.Ptr(),
+		HasDefaultValue: field.HasDefaultValue,
+	}
+	if fk := field.ForeignKeyDescriptor; fk != nil {
+		c.ForeignKey = &foreignKey{
+			ConstraintName:    fk.ConstraintName,
+			TableName:         fk.TableName,
+			ColumnName:        fk.ColumnName,
+			ForeignTableName:  fk.ForeignTableName,
+			ForeignColumnName: fk.ForeignColumnName,
+		}
+	}
+	return c
+}
+
+// fieldDescriptor converts the column of the file to the field descriptor
+func (c column) fieldDescriptor() types.FieldDescriptor {
+	field := types.FieldDescriptor{
+		Field:           c.Field,
+		Type:            c.Type,
+		Null:            c.Null,
+		Key:             c.Key,
+		Length:          null.IntFromPtr(c.Length),
+		Default:         null.StringFromPtr(c.Default),
+		Extra:           c.Extra,
+		Precision:       null.IntFromPtr(c.Precision),
+		Scale:           null.IntFromPtr(c.Scale),
+		HasDefaultValue: c.HasDefaultValue,
+	}
+	if fk := c.ForeignKey; fk != nil {
+		field.ForeignKeyDescriptor = &types.FKDescriptor{
+			ConstraintName:    fk.ConstraintName,
+			TableName:         fk.TableName,
+			ColumnName:        fk.ColumnName,
+			ForeignTableName:  fk.ForeignTableName,
+			ForeignColumnName: fk.ForeignColumnName,
+		}
+	}
+	return field
+}
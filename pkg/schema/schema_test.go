@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/volatiletech/null"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := Snapshot{
+		Driver:         "postgres",
+		InsertionOrder: []string{"users", "orders"},
+		Tables: map[string][]types.FieldDescriptor{
+			"users": {
+				{Field: "id", Type: "integer", Key: "PRI", HasDefaultValue: true, Default: null.StringFrom("nextval('users_id_seq'::regclass)")},
+				{Field: "name", Type: "character varying", Length: null.IntFrom(30)},
+			},
+			"orders": {
+				{Field: "price", Type: "numeric", Precision: null.IntFrom(10), Scale: null.IntFrom(2)},
+				{Field: "user_id", Type: "integer", ForeignKeyDescriptor: &types.FKDescriptor{ForeignTableName: "users", ForeignColumnName: "id"}},
+			},
+		},
+	}
+	path := filepath.Join(dir, "schema.json")
+	if err := Write(path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	output, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(output, snapshot) {
+		t.Errorf("Invalid snapshot, out: %+v expected: %+v", output, snapshot)
+	}
+}
+
+func TestReadFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "schema.json")
+	data := `{"driver": "mysql", "insertion_order": ["orders"], "tables": {"orders": [
+		{"field": "id", "type": "int", "key": "PRI", "extra": "auto_increment", "has_default_value": true},
+		{"field": "user_id", "type": "int", "length": 11, "foreign_key": {"foreign_table_name": "users", "foreign_column_name": "id"}}
+	]}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []types.FieldDescriptor{
+		{Field: "id", Type: "int", Key: "PRI", Extra: "auto_increment", HasDefaultValue: true},
+		{Field: "user_id", Type: "int", Length: null.IntFrom(11), ForeignKeyDescriptor: &types.FKDescriptor{ForeignTableName: "users", ForeignColumnName: "id"}},
+	}
+	if !reflect.DeepEqual(output.Tables["orders"], expected) {
+		t.Errorf("Invalid columns, out: %+v expected: %+v", output.Tables["orders"], expected)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var scenarios = []string{
+		`{"driver": "mysql", "insertion_order": ["users"], "tables": {}}`,
+		`{"insertion_order": [], "tables": {}}`,
+		`{"driver": "mysql", "tables": [`,
+	}
+
+	for _, scenario := range scenarios {
+		path := filepath.Join(dir, "schema.json")
+		if err := ioutil.WriteFile(path, []byte(scenario), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read(path); err == nil {
+			t.Errorf("Invalid snapshot should fail: %s", scenario)
+		}
+	}
+	if _, err := Read(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Missing snapshot should fail")
+	}
+}
@@ -0,0 +1,288 @@
+// Package sqlfuzz fills the tables of an existing database connection with
+// random rows. It is the library behind the sqlfuzz command for embedding
+// it in tests and tools:
+//
+//	fuzzer, err := sqlfuzz.New(db, sqlfuzz.WithSeed(1))
+//	if err != nil {
+//		return err
+//	}
+//	err = fuzzer.Fill(ctx, "users", 100)
+//
+// The fuzzer has no global state, every instance generates its rows from
+// its own random generator.
+package sqlfuzz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers"
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/action"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/fuzzer"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/generator"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/stats"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Fuzzer fills the tables of the database with random rows
+type Fuzzer struct {
+	db           *sql.DB
+	driverName   string
+	driver       types.Driver
+	seed         int64
+	rng          *rand.Rand
+	workers      int
+	queryTimeout time.Duration
+	retry        action.RetryPolicy
+	stats        *stats.Collector
+	recorder     action.Recorder
+	generators   *generator.Registry
+	typeMapping  map[string]types.Field
+	beforeInsert func(ctx context.Context, row *action.Row) error
+	afterInsert  func(ctx context.Context, row action.Row, key map[string]interface{}, err error)
+}
+
+// Option configures the fuzzer
+type Option func(*Fuzzer)
+
+// WithDriver sets the driver of the database (mysql, postgres), by default
+// it is detected from the driver of the connection
+func WithDriver(name string) Option {
+	return func(f *Fuzzer) {
+		f.driverName = name
+	}
+}
+
+// WithSeed sets the seed of the generated values for reproducible rows,
+// the rows are the same for the same seed if there is a single worker
+func WithSeed(seed int64) Option {
+	return func(f *Fuzzer) {
+		f.seed = seed
+	}
+}
+
+// WithWorkers sets the number of concurrent inserts, 1 by default
+func WithWorkers(workers int) Option {
+	return func(f *Fuzzer) {
+		f.workers = workers
+	}
+}
+
+// WithQueryTimeout limits every insert, 0 means no timeout
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(f *Fuzzer) {
+		f.queryTimeout = timeout
+	}
+}
+
+// WithRetry sets the retries of the inserts failed with transient errors,
+// by default 3 retries from 50ms backoff
+func WithRetry(retry action.RetryPolicy) Option {
+	return func(f *Fuzzer) {
+		f.retry = retry
+	}
+}
+
+// WithStats records the outcome of the inserts into the collector
+func WithStats(collector *stats.Collector) Option {
+	return func(f *Fuzzer) {
+		f.stats = collector
+	}
+}
+
+// WithRecorder records the primary keys of the inserted rows, into a
+// journal.Journal file or a journal.Memory
+func WithRecorder(recorder action.Recorder) Option {
+	return func(f *Fuzzer) {
+		f.recorder = recorder
+	}
+}
+
+// WithGenerator generates the values of the column by the generator, the
+// column is either table.column or a column name of every table
+func WithGenerator(column string, g generator.Generator) Option {
+	return func(f *Fuzzer) {
+		f.generators.RegisterColumn(column, g)
+	}
+}
+
+// WithTypeGenerator generates the values of the field type by the
+// generator instead of the built-in one
+func WithTypeGenerator(fieldType types.FieldType, g generator.Generator) Option {
+	return func(f *Fuzzer) {
+		f.generators.RegisterType(fieldType, g)
+	}
+}
+
+// WithTypes maps the raw column types to fields, they take precedence over
+// the mapping of the driver (e.g. "citext" to types.Text)
+func WithTypes(mapping map[string]types.Field) Option {
+	return func(f *Fuzzer) {
+		f.typeMapping = mapping
+	}
+}
+
+// WithBeforeInsert calls the hook with every generated row before its
+// insert. The hook can change the columns and values of the row, skip it by
+// returning action.ErrSkipRow or fail the insert by any other error. Every
+// retry generates a new row, so the hook is called again on every attempt.
+func WithBeforeInsert(hook func(ctx context.Context, row *action.Row) error) Option {
+	return func(f *Fuzzer) {
+		f.beforeInsert = hook
+	}
+}
+
+// WithAfterInsert calls the hook after the insert of every row with the
+// primary key of the row (nil if unknown) and the error of the insert. It
+// is called on every attempt, including the failed attempts of the retries.
+func WithAfterInsert(hook func(ctx context.Context, row action.Row, key map[string]interface{}, err error)) Option {
+	return func(f *Fuzzer) {
+		f.afterInsert = hook
+	}
+}
+
+// New creates a fuzzer of the database. The connection is used as it is,
+// it is not closed by the fuzzer.
+func New(db *sql.DB, opts ...Option) (*Fuzzer, error) {
+	if db == nil {
+		return nil, errors.New("sqlfuzz: database should not be nil")
+	}
+	f := &Fuzzer{
+		db:         db,
+		workers:    1,
+		generators: generator.NewRegistry(),
+		retry:      action.RetryPolicy{Attempts: 3, Backoff: 50 * time.Millisecond, MaxBackoff: 5 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.driverName == "" {
+		name, err := driverName(db)
+		if err != nil {
+			return nil, err
+		}
+		f.driverName = name
+	}
+	driver, err := drivers.New(types.Flags{Driver: f.driverName, Types: f.typeMapping})
+	if err != nil {
+		return nil, err
+	}
+	f.driver = driver
+	if f.seed == 0 {
+		f.seed = time.Now().UnixNano()
+	}
+	f.rng = generator.NewRand(f.seed)
+	return f, nil
+}
+
+// driverName detects the driver name of the connection
+func driverName(db *sql.DB) (string, error) {
+	switch db.Driver().(type) {
+	case *mysqldriver.MySQLDriver:
+		return "mysql", nil
+	case *pq.Driver:
+		return "postgres", nil
+	}
+	return "", fmt.Errorf("sqlfuzz: unknown database driver %T, set it by WithDriver", db.Driver())
+}
+
+// Driver returns the driver of the database
+func (f *Fuzzer) Driver() types.Driver {
+	return f.driver
+}
+
+// Seed returns the seed of the generated values, the time based one if it
+// was not set by WithSeed
+func (f *Fuzzer) Seed() int64 {
+	return f.seed
+}
+
+// Describe returns the described columns of the table
+func (f *Fuzzer) Describe(ctx context.Context, table string) ([]types.FieldDescriptor, error) {
+	return f.driver.Describe(ctx, table, f.db)
+}
+
+// Fill inserts n random rows into the table. Foreign keys reference the
+// latest row of the referenced table. It returns an error if any of the
+// inserts failed.
+func (f *Fuzzer) Fill(ctx context.Context, table string, n int) error {
+	fields, err := f.Describe(ctx, table)
+	if err != nil {
+		return err
+	}
+	return f.fill(ctx, table, fields, n)
+}
+
+// FillTables inserts the number of random rows into each table, the
+// referenced tables are filled first
+func (f *Fuzzer) FillTables(ctx context.Context, rows map[string]int) error {
+	tables := make([]string, 0, len(rows))
+	for table := range rows {
+		tables = append(tables, table)
+	}
+	tableToFields, insertionOrder, err := f.driver.MultiDescribe(ctx, tables, f.db)
+	if err != nil {
+		return err
+	}
+	for _, table := range insertionOrder {
+		n, ok := rows[table]
+		if !ok {
+			continue
+		}
+		if err := f.fill(ctx, table, tableToFields[table], n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fill inserts n rows into the table in the worker pool
+func (f *Fuzzer) fill(ctx context.Context, table string, fields []types.FieldDescriptor, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	input := action.SQLInsertInput{
+		SingleInsertParams: &action.SingleInsertParams{
+			DB:     f.db,
+			Driver: f.driver,
+			Table:  table,
+			Fields: fields,
+		},
+		Rand:         f.rng,
+		Generators:   f.generators,
+		Stats:        f.stats,
+		Journal:      f.recorder,
+		BeforeInsert: f.beforeInsert,
+		AfterInsert:  f.afterInsert,
+		QueryTimeout: f.queryTimeout,
+		Retry:        f.retry,
+	}
+	var mu sync.Mutex
+	var failed int
+	var firstErr error
+	pool := fuzzer.Pool{
+		Workers: f.workers,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		},
+	}
+	if err := pool.Insert(ctx, n, input); err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("sqlfuzz: %d of %d inserts into %s failed: %w", failed, n, table, firstErr)
+	}
+	return nil
+}
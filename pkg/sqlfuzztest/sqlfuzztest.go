@@ -0,0 +1,68 @@
+// Package sqlfuzztest fills tables with random rows in integration tests.
+// The rows are removed when the test finishes:
+//
+//	func TestOrders(t *testing.T) {
+//		sqlfuzztest.Fill(t, db, map[string]int{"users": 10, "orders": 100})
+//		...
+//	}
+package sqlfuzztest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/pkg/journal"
+	"github.com/PumpkinSeed/sqlfuzz/pkg/sqlfuzz"
+)
+
+// Seed is the default seed of the generated rows, tests get the same rows
+// on every run unless it is changed by sqlfuzz.WithSeed
+const Seed = 1
+
+// Fill inserts the number of random rows into each table, the referenced
+// tables are filled first. The test fails immediately if the rows can't be
+// inserted. The inserted rows are deleted by the cleanup of the test, the
+// rows of tables without primary key are left in the database. If the test
+// failed the seed and the primary keys of the inserted rows are logged. It
+// returns the primary keys of the inserted rows.
+func Fill(tb testing.TB, db *sql.DB, rows map[string]int, opts ...sqlfuzz.Option) []journal.Entry {
+	tb.Helper()
+	recorder := &journal.Memory{}
+	opts = append([]sqlfuzz.Option{sqlfuzz.WithSeed(Seed)}, opts...)
+	opts = append(opts, sqlfuzz.WithRecorder(recorder))
+	fuzzer, err := sqlfuzz.New(db, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		entries := recorder.Entries()
+		if tb.Failed() {
+			tb.Log(Report(fuzzer.Seed(), entries))
+		}
+		if _, err := journal.Clean(context.Background(), fuzzer.Driver(), db, entries); err != nil {
+			tb.Errorf("sqlfuzztest: cleanup: %v", err)
+		}
+	})
+	if err := fuzzer.FillTables(context.Background(), rows); err != nil {
+		tb.Fatal(err)
+	}
+	return recorder.Entries()
+}
+
+// Report formats the seed and the primary keys of the inserted rows
+func Report(seed int64, entries []journal.Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sqlfuzz: seed %d, %d rows inserted", seed, len(entries))
+	for _, entry := range entries {
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			key = []byte(fmt.Sprint(entry.Key))
+		}
+		fmt.Fprintf(&b, "\n%s %s", entry.Table, key)
+	}
+	return b.String()
+}
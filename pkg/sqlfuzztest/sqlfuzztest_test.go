@@ -0,0 +1,32 @@
+package sqlfuzztest
+
+import (
+	"testing"
+
+	"github.com/PumpkinSeed/sqlfuzz/pkg/journal"
+)
+
+func TestReport(t *testing.T) {
+	var scenarios = []struct {
+		entries []journal.Entry
+		output  string
+	}{
+		{
+			entries: nil,
+			output:  "sqlfuzz: seed 1, 0 rows inserted",
+		},
+		{
+			entries: []journal.Entry{
+				{Table: "users", Key: map[string]interface{}{"id": 1}},
+				{Table: "order_items", Key: map[string]interface{}{"order_id": 7, "sku": "a1"}},
+			},
+			output: "sqlfuzz: seed 1, 2 rows inserted\nusers {\"id\":1}\norder_items {\"order_id\":7,\"sku\":\"a1\"}",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		if output := Report(1, scenario.entries); output != scenario.output {
+			t.Errorf("Invalid report, out: %q expected: %q", output, scenario.output)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// Buckets are the upper bounds of the latency histogram buckets, they grow
+// by sqrt(2) from 100µs to ~26s
+var Buckets = func() []time.Duration {
+	buckets := make([]time.Duration, 37)
+	for i := range buckets {
+		buckets[i] = time.Duration(float64(100*time.Microsecond) * math.Pow(math.Sqrt2, float64(i)))
+	}
+	return buckets
+}()
+
+// Histogram counts the latencies in the Buckets, the last count is the
+// overflow bucket
+type Histogram struct {
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() Histogram {
+	return Histogram{Counts: make([]int64, len(Buckets)+1)}
+}
+
+// Observe adds a latency to the histogram
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(Buckets) && d > Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Percentile estimates the q (0-1) percentile by linear interpolation
+// inside the bucket
+func (h Histogram) Percentile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := q * float64(h.Count)
+	var cumulative int64
+	for i, count := range h.Counts {
+		if count == 0 || float64(cumulative+count) < rank {
+			cumulative += count
+			continue
+		}
+		if i == len(Buckets) {
+			return Buckets[len(Buckets)-1]
+		}
+		lower := time.Duration(0)
+		if i > 0 {
+			lower = Buckets[i-1]
+		}
+		ratio := (rank - float64(cumulative)) / float64(count)
+		return lower + time.Duration(ratio*float64(Buckets[i]-lower))
+	}
+	return Buckets[len(Buckets)-1]
+}
+
+// copy returns a deep copy of the histogram
+func (h Histogram) copy() Histogram {
+	h.Counts = append([]int64(nil), h.Counts...)
+	return h
+}
@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+	var scenarios = []struct {
+		q        float64
+		expected time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.95, 950 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+	}
+
+	for _, scenario := range scenarios {
+		p := h.Percentile(scenario.q)
+		// The buckets grow by sqrt(2), the estimation is within the bucket
+		if p < scenario.expected*7/10 || p > scenario.expected*14/10 {
+			t.Errorf("Invalid p%v, out: %v expected about: %v", scenario.q*100, p, scenario.expected)
+		}
+	}
+	if h.Count != 1000 || h.Sum != 500500*time.Millisecond {
+		t.Errorf("Invalid count or sum, out: %d %v", h.Count, h.Sum)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	if p := h.Percentile(0.99); p != 0 {
+		t.Errorf("Empty histogram percentile should be 0, out: %v", p)
+	}
+}
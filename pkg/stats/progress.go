@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReportProgress logs the progress of the unfinished tables in every
+// interval until the context is done
+func (c *Collector) ReportProgress(ctx context.Context, interval time.Duration) {
+	if c == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := make(map[string]Table)
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(last)
+			last = now
+			for _, t := range c.Summary().Tables {
+				prev, seen := previous[t.Name]
+				previous[t.Name] = t
+				if seen && prev.Inserted == t.Inserted && prev.Failed == t.Failed {
+					continue
+				}
+				log.Print(progressLine(t, prev, elapsed))
+			}
+		}
+	}
+}
+
+// progressLine formats the progress of a table since the previous report
+func progressLine(t, prev Table, elapsed time.Duration) string {
+	rate := float64(t.Inserted-prev.Inserted) / elapsed.Seconds()
+	done := t.Inserted + t.Failed
+	if t.Total <= 0 {
+		return fmt.Sprintf("Progress %s: %d rows, %.1f rows/s, %d errors", t.Name, t.Inserted, rate, t.Failed)
+	}
+	eta := "-"
+	if remaining := t.Total - done; remaining <= 0 {
+		eta = "0s"
+	} else if rate > 0 {
+		eta = time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("Progress %s: %d/%d rows (%.1f%%), %.1f rows/s, %d errors, ETA %s",
+		t.Name, done, t.Total, float64(done)/float64(t.Total)*100, rate, t.Failed, eta)
+}
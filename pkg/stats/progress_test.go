@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	var scenarios = []struct {
+		t      Table
+		prev   Table
+		output string
+	}{
+		{
+			t:      Table{Name: "users", Total: 1000, Inserted: 500, Failed: 2},
+			prev:   Table{Name: "users", Total: 1000, Inserted: 300},
+			output: "Progress users: 502/1000 rows (50.2%), 20.0 rows/s, 2 errors, ETA 25s",
+		},
+		{
+			t:      Table{Name: "users", Total: 1000, Inserted: 500},
+			prev:   Table{Name: "users", Total: 1000, Inserted: 500},
+			output: "Progress users: 500/1000 rows (50.0%), 0.0 rows/s, 0 errors, ETA -",
+		},
+		{
+			t:      Table{Name: "events", Inserted: 100},
+			output: "Progress events: 100 rows, 10.0 rows/s, 0 errors",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		if output := progressLine(scenario.t, scenario.prev, 10*time.Second); output != scenario.output {
+			t.Errorf("Invalid progress line, out: %q expected: %q", output, scenario.output)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package stats
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+// maxSamples is the number of distinct error messages kept per table
+const maxSamples = 5
+
+// ErrErrorRateExceeded is returned when the ratio of the failed inserts of
+// a table is above the threshold
+var ErrErrorRateExceeded = errors.New("stats: error rate threshold exceeded")
+
+// Latency holds the insert latency percentiles in milliseconds
+type Latency struct {
+	P50 float64 `json:"p50_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+// Table holds the counters of a single table
+type Table struct {
+	Name string `json:"name"`
+	// Total is the number of rows planned to insert, 0 if unknown
+	Total    int64                         `json:"total,omitempty"`
+	Inserted int64                         `json:"inserted"`
+	Failed   int64                         `json:"failed"`
+	Retries  int64                         `json:"retries"`
+	Errors   map[types.ErrorCategory]int64 `json:"errors,omitempty"`
+	Samples  []string                      `json:"samples,omitempty"`
+	Latency  Latency                       `json:"latency"`
+}
+
+// ErrorRate returns the ratio of the failed inserts
+func (t Table) ErrorRate() float64 {
+	if t.Inserted+t.Failed == 0 {
+		return 0
+	}
+	return float64(t.Failed) / float64(t.Inserted+t.Failed)
+}
+
+// Summary is the outcome of the run
+type Summary struct {
+	Inserted int64   `json:"inserted"`
+	Failed   int64   `json:"failed"`
+	Seconds  float64 `json:"seconds"`
+	Tables   []Table `json:"tables"`
+}
+
+// String returns the summary in a human readable form
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %d rows inserted, %d failed in %d tables, taken: %v",
+		s.Inserted, s.Failed, len(s.Tables), time.Duration(s.Seconds*float64(time.Second)).Round(time.Millisecond))
+	for _, t := range s.Tables {
+		fmt.Fprintf(&b, "\n  %s: %d inserted, %d failed, %d retries, latency p50 %.2fms p95 %.2fms p99 %.2fms",
+			t.Name, t.Inserted, t.Failed, t.Retries, t.Latency.P50, t.Latency.P95, t.Latency.P99)
+		categories := make([]string, 0, len(t.Errors))
+		for category := range t.Errors {
+			categories = append(categories, string(category))
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			fmt.Fprintf(&b, ", %s: %d", category, t.Errors[types.ErrorCategory(category)])
+		}
+	}
+	return b.String()
+}
+
+// tableStats is the mutable state of a table
+type tableStats struct {
+	Table
+	latency Histogram
+}
+
+// Collector collects the outcome of the inserts per table. It is safe for
+// concurrent use, a nil Collector discards everything.
+type Collector struct {
+	mu     sync.Mutex
+	start  time.Time
+	tables map[string]*tableStats
+	dbs    map[*sql.DB]struct{}
+}
+
+// New creates a Collector, the run is timed from now
+func New() *Collector {
+	return &Collector{
+		start:  time.Now(),
+		tables: make(map[string]*tableStats),
+		dbs:    make(map[*sql.DB]struct{}),
+	}
+}
+
+// TrackDB adds the connection pool used by the inserts to the DBStats
+func (c *Collector) TrackDB(db *sql.DB) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dbs[db] = struct{}{}
+}
+
+// UntrackDB removes the connection pool from the DBStats
+func (c *Collector) UntrackDB(db *sql.DB) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.dbs, db)
+}
+
+// DBStats returns the sum of the statistics of the tracked connection pools
+func (c *Collector) DBStats() sql.DBStats {
+	var s sql.DBStats
+	if c == nil {
+		return s
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for db := range c.dbs {
+		dbStats := db.Stats()
+		s.MaxOpenConnections += dbStats.MaxOpenConnections
+		s.OpenConnections += dbStats.OpenConnections
+		s.InUse += dbStats.InUse
+		s.Idle += dbStats.Idle
+		s.WaitCount += dbStats.WaitCount
+		s.WaitDuration += dbStats.WaitDuration
+	}
+	return s
+}
+
+// SetTotal sets the number of rows planned to insert into the table, the
+// tables without planned rows are not added to the summary
+func (c *Collector) SetTotal(table string, total int64) {
+	if c == nil {
+		return
+	}
+	if total == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.table(table).Total += total
+}
+
+// Record records the outcome of an insert into the table, the latency and
+// the category are ignored for failed and successful inserts respectively
+func (c *Collector) Record(table string, took time.Duration, category types.ErrorCategory, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.table(table)
+	if err == nil {
+		t.Inserted++
+		t.latency.Observe(took)
+		return
+	}
+	t.Failed++
+	t.Errors[category]++
+	if len(t.Samples) < maxSamples {
+		msg := err.Error()
+		for _, sample := range t.Samples {
+			if sample == msg {
+				return
+			}
+		}
+		t.Samples = append(t.Samples, msg)
+	}
+}
+
+// RecordRetry records that a failed insert into the table is retried
+func (c *Collector) RecordRetry(table string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.table(table).Retries++
+}
+
+// Table returns a copy of the counters of the table, the counters are
+// zero if nothing was recorded into the table
+func (c *Collector) Table(name string) Table {
+	if c == nil {
+		return Table{Name: name}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tables[name]
+	if !ok {
+		return Table{Name: name}
+	}
+	return t.copy()
+}
+
+// Histogram returns a copy of the latency histogram of the table
+func (c *Collector) Histogram(name string) Histogram {
+	if c == nil {
+		return NewHistogram()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tables[name]
+	if !ok {
+		return NewHistogram()
+	}
+	return t.latency.copy()
+}
+
+// Summary returns a copy of the counters ordered by table name
+func (c *Collector) Summary() Summary {
+	if c == nil {
+		return Summary{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Summary{
+		Seconds: time.Since(c.start).Seconds(),
+		Tables:  make([]Table, 0, len(c.tables)),
+	}
+	for _, t := range c.tables {
+		s.Inserted += t.Inserted
+		s.Failed += t.Failed
+		s.Tables = append(s.Tables, t.copy())
+	}
+	sort.Slice(s.Tables, func(i, j int) bool { return s.Tables[i].Name < s.Tables[j].Name })
+	return s
+}
+
+// CheckErrorRate returns ErrErrorRateExceeded if the ratio of the failed
+// inserts of the table is above max
+func (c *Collector) CheckErrorRate(table string, max float64) error {
+	t := c.Table(table)
+	if rate := t.ErrorRate(); rate > max {
+		return fmt.Errorf("%w: %.2f%% of the inserts into %s failed, maximum is %.2f%%",
+			ErrErrorRateExceeded, rate*100, table, max*100)
+	}
+	return nil
+}
+
+// table returns the state of the table, c.mu should be held
+func (c *Collector) table(name string) *tableStats {
+	t, ok := c.tables[name]
+	if !ok {
+		t = &tableStats{
+			Table:   Table{Name: name, Errors: make(map[types.ErrorCategory]int64)},
+			latency: NewHistogram(),
+		}
+		c.tables[name] = t
+	}
+	return t
+}
+
+// copy returns a deep copy of the table counters with the latency
+// percentiles
+func (t *tableStats) copy() Table {
+	cp := t.Table
+	cp.Errors = make(map[types.ErrorCategory]int64, len(t.Errors))
+	for category, count := range t.Errors {
+		cp.Errors[category] = count
+	}
+	cp.Samples = append([]string(nil), t.Samples...)
+	cp.Latency = Latency{
+		P50: milliseconds(t.latency.Percentile(0.50)),
+		P95: milliseconds(t.latency.Percentile(0.95)),
+		P99: milliseconds(t.latency.Percentile(0.99)),
+	}
+	return cp
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/sqlfuzz/drivers/types"
+)
+
+func TestCollectorRecord(t *testing.T) {
+	c := New()
+	wg := &sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				c.Record("users", time.Millisecond, "", nil)
+				if i%10 == 0 {
+					c.Record("orders", 0, types.ConstraintViolation, errors.New("duplicate key"))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	c.Record("orders", 0, types.ConnectionError, errors.New("connection reset"))
+	c.RecordRetry("orders")
+
+	s := c.Summary()
+	if len(s.Tables) != 2 {
+		t.Fatalf("Invalid number of tables, out: %d", len(s.Tables))
+	}
+	orders := s.Tables[0]
+	if orders.Name != "orders" || orders.Inserted != 0 || orders.Failed != 41 {
+		t.Errorf("Invalid orders counters, out: %+v", orders)
+	}
+	if orders.Errors[types.ConstraintViolation] != 40 || orders.Errors[types.ConnectionError] != 1 {
+		t.Errorf("Invalid orders error categories, out: %+v", orders.Errors)
+	}
+	if orders.Retries != 1 {
+		t.Errorf("Invalid orders retries, out: %d", orders.Retries)
+	}
+	if len(orders.Samples) != 2 {
+		t.Errorf("Samples should be distinct, out: %v", orders.Samples)
+	}
+	users := s.Tables[1]
+	if users.Name != "users" || users.Inserted != 400 || users.Failed != 0 {
+		t.Errorf("Invalid users counters, out: %+v", users)
+	}
+	if users.Latency.P50 < 0.8 || users.Latency.P99 > 1.14 {
+		t.Errorf("Invalid users latency, out: %+v", users.Latency)
+	}
+	if summary := s.String(); !strings.Contains(summary, "400 rows inserted, 41 failed in 2 tables") ||
+		!strings.Contains(summary, "constraint_violation: 40") {
+		t.Errorf("Invalid summary: %s", summary)
+	}
+}
+
+func TestCollectorCheckErrorRate(t *testing.T) {
+	c := New()
+	for i := 0; i < 10; i++ {
+		var err error
+		if i < 3 {
+			err = errors.New("type mismatch")
+		}
+		c.Record("users", time.Millisecond, types.TypeMismatch, err)
+	}
+	if err := c.CheckErrorRate("users", 0.5); err != nil {
+		t.Errorf("30%% error rate should be below 50%%: %v", err)
+	}
+	if err := c.CheckErrorRate("users", 0.1); !errors.Is(err, ErrErrorRateExceeded) {
+		t.Errorf("30%% error rate should exceed 10%%, out: %v", err)
+	}
+	if err := c.CheckErrorRate("empty", 0); err != nil {
+		t.Errorf("Table without inserts should not fail: %v", err)
+	}
+	c.SetTotal("missing", 0)
+	if tables := c.Summary().Tables; len(tables) != 1 {
+		t.Errorf("Tables without inserts should not be in the summary, out: %+v", tables)
+	}
+}
+
+func TestCollectorNil(t *testing.T) {
+	var c *Collector
+	c.Record("users", time.Millisecond, "", nil)
+	c.RecordRetry("users")
+	if len(c.Summary().Tables) != 0 || c.CheckErrorRate("users", 0) != nil {
+		t.Error("nil collector should be empty")
+	}
+}